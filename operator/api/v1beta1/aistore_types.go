@@ -25,6 +25,7 @@ const (
 	ConditionCreated               ClusterCondition = "Created"
 	ConditionReady                 ClusterCondition = "Ready"
 	ConditionUpgrading             ClusterCondition = "Upgrading"
+	ConditionHibernated            ClusterCondition = "Hibernated"
 	// TODO: Add more states, eg. Terminating etc.
 
 	// Condition types
@@ -45,6 +46,7 @@ const (
 	ResourceCreationError ErrorReason = "ResourceCreationError"
 	ResourceFetchError    ErrorReason = "ResouceFetchError" // failed to fetch a resource using K8s API
 	ResourceUpdateError   ErrorReason = "ResourceUpdateError"
+	StorageClassError     ErrorReason = "StorageClassError" // referenced StorageClass doesn't exist
 
 	defaultClusterDomain = "cluster.local"
 )
@@ -94,6 +96,17 @@ type AIStoreSpec struct {
 	DisablePodAntiAffinity *bool `json:"disablePodAntiAffinity,omitempty"`
 	// EnableExternalLB, if set, enables external access to AIS cluster using LoadBalancer service
 	EnableExternalLB bool `json:"enableExternalLB"`
+	// CommonLabels are added to the statefulsets, services and PVCs created by the operator, e.g. cost-center or team.
+	// They never override the labels set by the operator itself.
+	// +optional
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+	// CommonAnnotations are added to the statefulsets, services and PVCs created by the operator.
+	// +optional
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+	// Hibernate, if set, gracefully shuts the cluster down and scales it to zero, retaining the PVCs and so the data.
+	// Unsetting it brings the cluster back up.
+	// +optional
+	Hibernate bool `json:"hibernate,omitempty"`
 }
 
 // AIStoreStatus defines the observed state of AIStore
@@ -109,6 +122,12 @@ type AIStoreStatus struct {
 	State ClusterCondition `json:"state"`
 	// +optional
 	ConsecutiveErrorCount int `json:"consecutive_error_count"` // number of times an error occurred
+	// SmapVersion is the version of the cluster map (Smap) last observed in the AIS cluster.
+	// +optional
+	SmapVersion int64 `json:"smap_version"`
+	// ActiveTargets is the number of active (not in maintenance) targets in the last observed cluster map.
+	// +optional
+	ActiveTargets int `json:"active_targets"`
 }
 
 // ServiceSpec defines the specs of AIS Gateways
@@ -148,6 +167,21 @@ type TargetSpec struct {
 	// AllowSharedOrNoDisks - disables FsID and mountpath disks validation on target nodes. NOT recommended for production deployments
 	// +optional
 	AllowSharedOrNoDisks *bool `json:"allowSharedNoDisks,omitempty"`
+	// DiskInitContainer - init container preparing (e.g. formatting, validating) the mountpaths before the target starts.
+	// The mountpaths are mounted at the same paths as in the target container.
+	// +optional
+	DiskInitContainer *InitContainerSpec `json:"diskInitContainer,omitempty"`
+}
+
+// InitContainerSpec defines a custom init container of AIS Daemon pods
+type InitContainerSpec struct {
+	Image string `json:"image"`
+	// Command - entrypoint array, the image's ENTRYPOINT is used if not provided
+	// +optional
+	Command []string `json:"command,omitempty"`
+	// Args - arguments to the entrypoint, the image's CMD is used if not provided
+	// +optional
+	Args []string `json:"args,omitempty"`
 }
 
 type Mount struct {
@@ -0,0 +1,797 @@
+// Package client contains wrapper for k8s client
+/*
+ * Copyright (c) 2021, NVIDIA CORPORATION. All rights reserved.
+ */
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aisapi "github.com/NVIDIA/aistore/api"
+	aisapc "github.com/NVIDIA/aistore/api/apc"
+	aiscluster "github.com/NVIDIA/aistore/cluster"
+	aiscmn "github.com/NVIDIA/aistore/cmn"
+	aiscos "github.com/NVIDIA/aistore/cmn/cos"
+	aisxact "github.com/NVIDIA/aistore/xact"
+	aisv1 "github.com/ais-operator/api/v1beta1"
+)
+
+// defaultAISRequestTimeout is the timeout of a single AIS API request, unless the context has a closer deadline.
+const defaultAISRequestTimeout = time.Minute
+
+// aisTransport is shared across all AIS API requests to reuse connections.
+var aisTransport = aiscmn.NewTransport(aiscmn.TransportArgs{
+	IdleConnsPerHost: 100,
+	UseHTTPProxyEnv:  true,
+})
+
+// aisBaseParams returns the AIS API params for the proxy URL.
+// AIS API doesn't accept a context, so the request timeout is bound by the context deadline instead.
+func aisBaseParams(ctx context.Context, proxyURL string) (params aisapi.BaseParams, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	timeout := defaultAISRequestTimeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+	params = aisapi.BaseParams{
+		Client: &http.Client{Transport: aisTransport, Timeout: timeout},
+		URL:    proxyURL,
+	}
+	return
+}
+
+// CheckAISHealth checks if the AIS cluster is healthy through the proxy at `proxyURL`.
+// Unlike the pod phase, it reports an error until the node has joined the cluster.
+func CheckAISHealth(ctx context.Context, proxyURL string) error {
+	params, err := aisBaseParams(ctx, proxyURL)
+	if err != nil {
+		return err
+	}
+	return aisapi.Health(params)
+}
+
+// GetSmap fetches the cluster map (Smap) through the proxy at `proxyURL`,
+// describing cluster membership (proxies, targets), the primary proxy and the Smap version.
+func GetSmap(ctx context.Context, proxyURL string) (*aiscluster.Smap, error) {
+	params, err := aisBaseParams(ctx, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	return aisapi.GetClusterMap(params)
+}
+
+// GetSmapForValidation fetches the live cluster map bounded by `ValidationTimeout`, e.g. for a validating
+// webhook to reject a scale-down below the number of nodes required for quorum.
+func GetSmapForValidation(ctx context.Context, proxyURL string) (*aiscluster.Smap, error) {
+	ctx, cancel := context.WithTimeout(ctx, ValidationTimeout)
+	defer cancel()
+	return GetSmap(ctx, proxyURL)
+}
+
+// GetPrimaryProxy returns the node ID of the primary proxy, as seen by the proxy at `proxyURL`.
+func GetPrimaryProxy(ctx context.Context, proxyURL string) (nodeID string, err error) {
+	smap, err := GetSmap(ctx, proxyURL)
+	if err != nil {
+		return "", err
+	}
+	if smap.Primary == nil {
+		return "", fmt.Errorf("cluster at %q has no primary proxy", proxyURL)
+	}
+	return smap.Primary.ID(), nil
+}
+
+// SetPrimaryProxy designates the proxy node as the new primary, e.g. to move the primary role off a proxy
+// before restarting it. No-op if the node is already the primary.
+func SetPrimaryProxy(ctx context.Context, proxyURL, nodeID string) error {
+	primaryID, err := GetPrimaryProxy(ctx, proxyURL)
+	if err != nil || primaryID == nodeID {
+		return err
+	}
+	params, err := aisBaseParams(ctx, proxyURL)
+	if err != nil {
+		return err
+	}
+	return aisapi.SetPrimaryProxy(params, nodeID, false /*force*/)
+}
+
+// CheckProxyConsensus queries each proxy for its view of the primary and returns ErrNoProxyConsensus if they disagree,
+// e.g. on split-brain. A proxy without a primary (election in progress) disagrees with any proxy that has one.
+// Unreachable proxies are ignored, unless none of the proxies could be reached.
+func CheckProxyConsensus(ctx context.Context, proxyURLs []string) error {
+	var (
+		primaries = make(map[string][]string, 1) // primary ID => proxy URLs
+		lastErr   error
+	)
+	for _, proxyURL := range proxyURLs {
+		smap, err := GetSmap(ctx, proxyURL)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		primaryID := "<none>"
+		if smap.Primary != nil {
+			primaryID = smap.Primary.ID()
+		}
+		primaries[primaryID] = append(primaries[primaryID], proxyURL)
+	}
+	switch len(primaries) {
+	case 0:
+		if lastErr == nil {
+			return errors.New("no proxies to check")
+		}
+		return fmt.Errorf("failed to reach any proxy, last error: %w", lastErr)
+	case 1:
+		return nil
+	default:
+		return fmt.Errorf("%w: %v", ErrNoProxyConsensus, primaries)
+	}
+}
+
+// ListBuckets lists the names of the AIS (`ais://`) buckets through the proxy at `proxyURL`. Remote buckets aren't listed,
+// to avoid depending on the cloud backends. A successful listing shows the cluster is serving control-plane requests.
+func ListBuckets(ctx context.Context, proxyURL string) ([]string, error) {
+	params, err := aisBaseParams(ctx, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	bcks, err := aisapi.ListBuckets(params, aiscmn.QueryBcks{Provider: aisapc.ProviderAIS})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(bcks))
+	for i := range bcks {
+		names = append(names, bcks[i].Name)
+	}
+	return names, nil
+}
+
+type (
+	// ClusterStats is the storage capacity of the cluster, summed over the mountpaths of all targets.
+	ClusterStats struct {
+		TotalBytes uint64
+		UsedBytes  uint64
+		Targets    map[string]TargetCapacity // by target node ID
+	}
+	// TargetCapacity is the storage capacity of a target, summed over its mountpaths.
+	TargetCapacity struct {
+		TotalBytes uint64
+		UsedBytes  uint64
+	}
+)
+
+// GetClusterStats fetches the capacity of all the targets through the proxy at `proxyURL`, e.g. to report
+// how full the cluster is. Targets whose stats couldn't be fetched are missing from the breakdown.
+func GetClusterStats(ctx context.Context, proxyURL string) (*ClusterStats, error) {
+	params, err := aisBaseParams(ctx, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	clusterStats, err := aisapi.GetClusterStats(params)
+	if err != nil {
+		return nil, err
+	}
+	result := &ClusterStats{Targets: make(map[string]TargetCapacity, len(clusterStats.Target))}
+	for id, targetStats := range clusterStats.Target {
+		if targetStats == nil {
+			continue
+		}
+		var capacity TargetCapacity
+		for _, mpathCap := range targetStats.MPCap {
+			capacity.UsedBytes += mpathCap.Used
+			capacity.TotalBytes += mpathCap.Used + mpathCap.Avail
+		}
+		result.Targets[id] = capacity
+		result.UsedBytes += capacity.UsedBytes
+		result.TotalBytes += capacity.TotalBytes
+	}
+	return result, nil
+}
+
+// ClusterHealth summarizes the state of the AIS cluster, as seen by both K8s and AIS (see GetClusterHealth).
+type ClusterHealth struct {
+	ReadyProxies   int
+	ReadyTargets   int
+	PrimaryElected bool     // false also if the cluster map couldn't be fetched
+	Rebalancing    bool     // a global rebalance is in progress
+	DegradedPods   []string // AIS pods that aren't ready, sorted by name
+}
+
+// IsHealthy checks that all `size` proxies and targets are ready and a primary is elected.
+// A running rebalance doesn't make the cluster unhealthy.
+func (h *ClusterHealth) IsHealthy(size int32) bool {
+	return h.PrimaryElected && len(h.DegradedPods) == 0 &&
+		h.ReadyProxies >= int(size) && h.ReadyTargets >= int(size)
+}
+
+// GetClusterHealth combines the readiness of the AIS pods with the cluster map and rebalance state
+// fetched through the proxy at `proxyURL`, e.g. to set the Ready condition of the CR consistently.
+// Only K8s API errors are returned; AIS being unreachable is reported as no primary elected.
+func (c *K8sClient) GetClusterHealth(ctx context.Context, ais *aisv1.AIStore, proxyURL string) (*ClusterHealth, error) {
+	pods, err := c.ListPods(ctx, ais.Namespace, client.MatchingLabels{"app": ais.Name})
+	if err != nil {
+		return nil, err
+	}
+	health := &ClusterHealth{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !isPodReady(pod) {
+			health.DegradedPods = append(health.DegradedPods, pod.Name)
+			continue
+		}
+		switch pod.Labels["component"] {
+		case aisapc.Proxy:
+			health.ReadyProxies++
+		case aisapc.Target:
+			health.ReadyTargets++
+		}
+	}
+	sort.Strings(health.DegradedPods)
+
+	if smap, err := GetSmap(ctx, proxyURL); err == nil {
+		health.PrimaryElected = smap.Primary != nil && smap.Primary.ID() != ""
+	}
+	if running, _, err := GetRebalanceStatus(ctx, proxyURL); err == nil {
+		health.Rebalancing = running
+	}
+	return health, nil
+}
+
+// ClusterMetadataSnapshot is the cluster metadata serialized by BackupClusterMetadata.
+type ClusterMetadataSnapshot struct {
+	Time time.Time        `json:"time"`
+	Smap *aiscluster.Smap `json:"smap"`
+	BMD  *aiscluster.BMD  `json:"bmd"`
+}
+
+// BackupClusterMetadata fetches the cluster map (Smap) and the bucket metadata (BMD) through the proxy at `proxyURL`
+// and serializes them as JSON, e.g. to be stored in a ConfigMap before a disruptive change (see RestoreClusterMetadata).
+func BackupClusterMetadata(ctx context.Context, proxyURL string) ([]byte, error) {
+	params, err := aisBaseParams(ctx, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := ClusterMetadataSnapshot{Time: time.Now().UTC()}
+	if snapshot.Smap, err = aisapi.GetClusterMap(params); err != nil {
+		return nil, err
+	}
+	if snapshot.BMD, err = aisapi.GetBMD(params); err != nil {
+		return nil, err
+	}
+	return json.Marshal(snapshot)
+}
+
+// RestoreClusterMetadata recreates the AIS buckets of the snapshot taken by BackupClusterMetadata that no longer
+// exist in the cluster, returning their names. The buckets are recreated with the default properties.
+// AIS has no API to overwrite the Smap or BMD, so the node membership isn't restored: the nodes rejoin the
+// cluster on their own, and the Smap in the snapshot serves as a reference only.
+func RestoreClusterMetadata(ctx context.Context, proxyURL string, data []byte) (restored []string, err error) {
+	snapshot := ClusterMetadataSnapshot{}
+	if err = json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid cluster metadata snapshot: %w", err)
+	}
+	if snapshot.BMD == nil {
+		return nil, errors.New("invalid cluster metadata snapshot: no BMD")
+	}
+	params, err := aisBaseParams(ctx, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	current, err := aisapi.GetBMD(params)
+	if err != nil {
+		return nil, err
+	}
+	provider := aisapc.ProviderAIS
+	existing := make(map[string]struct{})
+	current.Range(&provider, nil, func(bck *aiscluster.Bck) bool {
+		existing[bck.Bucket().String()] = struct{}{}
+		return false
+	})
+	var missing []aiscmn.Bck
+	snapshot.BMD.Range(&provider, nil, func(bck *aiscluster.Bck) bool {
+		if _, ok := existing[bck.Bucket().String()]; !ok {
+			missing = append(missing, *bck.Bucket())
+		}
+		return false
+	})
+	for i := range missing {
+		if err = aisapi.CreateBucket(params, missing[i], nil); err != nil {
+			return restored, err
+		}
+		restored = append(restored, missing[i].String())
+	}
+	sort.Strings(restored)
+	return restored, nil
+}
+
+// WaitForClusterQuorum waits until a primary proxy is elected and the cluster map (Smap) stabilizes,
+// i.e. its version doesn't change between two consecutive polls.
+func WaitForClusterQuorum(ctx context.Context, proxyURL string, timeout time.Duration) error {
+	return waitForClusterQuorum(ctx, proxyURL, timeout, DefaultRetryInterval)
+}
+
+func waitForClusterQuorum(ctx context.Context, proxyURL string, timeout, retryInterval time.Duration) error {
+	ctxBack, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var (
+		lastVersion int64 = -1
+		lastErr     error
+	)
+	for {
+		var smap *aiscluster.Smap
+		smap, lastErr = GetSmap(ctxBack, proxyURL)
+		if lastErr == nil && smap.Primary != nil {
+			if smap.Version == lastVersion {
+				return nil
+			}
+			lastVersion = smap.Version
+		}
+		select {
+		case <-ctxBack.Done():
+			if lastErr != nil {
+				return fmt.Errorf("cluster at %q has no quorum, last error: %v: %w", proxyURL, lastErr, ctxBack.Err())
+			}
+			return fmt.Errorf("cluster at %q has no quorum (smap version %d): %w", proxyURL, lastVersion, ctxBack.Err())
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// TriggerConfigReload pushes the config update to all the daemons of the cluster through the proxy at `proxyURL`,
+// applying it without restarts. Only the settings that AIS supports updating at runtime can be changed this way.
+func TriggerConfigReload(ctx context.Context, proxyURL string, toUpdate *aiscmn.ConfigToUpdate) error {
+	params, err := aisBaseParams(ctx, proxyURL)
+	if err != nil {
+		return err
+	}
+	return aisapi.SetClusterConfigUsingMsg(params, toUpdate)
+}
+
+// ApplyClusterConfig converges the cluster-wide config to `config`, keyed by the dotted config names
+// (e.g. "rebalance.enabled"). Only the values which differ from the current cluster config are set,
+// so calling it repeatedly doesn't bump the config version unless there was a drift.
+// The values are compared once parsed, so e.g. "1m" matches the current duration of "1m0s".
+func ApplyClusterConfig(ctx context.Context, proxyURL string, config map[string]string) (updated bool, err error) {
+	params, err := aisBaseParams(ctx, proxyURL)
+	if err != nil {
+		return
+	}
+	current, err := aisapi.GetClusterConfig(params)
+	if err != nil {
+		return
+	}
+	// Apply the values to a copy of the current config, to normalize them.
+	desired := &aiscmn.ClusterConfig{}
+	if err = copyClusterConfig(current, desired); err != nil {
+		return
+	}
+	for name, value := range config {
+		if err = aiscmn.UpdateFieldValue(desired, name, value); err != nil {
+			return false, fmt.Errorf("invalid cluster config %q: %w", name, err)
+		}
+	}
+	currentValues, err := clusterConfigValues(current)
+	if err != nil {
+		return
+	}
+	desiredValues, err := clusterConfigValues(desired)
+	if err != nil {
+		return
+	}
+
+	toSet := make(aiscos.SimpleKVs)
+	for name, value := range config {
+		if !reflect.DeepEqual(currentValues[name], desiredValues[name]) {
+			toSet[name] = value
+		}
+	}
+	if len(toSet) == 0 {
+		return
+	}
+	if err = aisapi.SetClusterConfig(params, toSet); err != nil {
+		return
+	}
+	return true, nil
+}
+
+func copyClusterConfig(src, dst *aiscmn.ClusterConfig) error {
+	b, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
+
+// clusterConfigValues returns the values of the config, keyed by the dotted config names.
+func clusterConfigValues(config *aiscmn.ClusterConfig) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	err := aiscmn.IterFields(config, func(name string, field aiscmn.IterField) (error, bool) {
+		values[name] = field.Value()
+		return nil, false
+	})
+	return values, err
+}
+
+// DecommissionTarget decommissions the target `targetID` and waits for the rebalance it triggers to finish,
+// so that the data stored on the target is migrated before its pod is removed.
+// The wait is bounded by the context; decommissioning continues in the cluster if the context is done first.
+func DecommissionTarget(ctx context.Context, proxyURL, targetID string) error {
+	return decommissionTarget(ctx, proxyURL, targetID, DefaultRetryInterval)
+}
+
+func decommissionTarget(ctx context.Context, proxyURL, targetID string, retryInterval time.Duration) error {
+	rebID, err := StartDecommissionTarget(ctx, proxyURL, targetID)
+	if err != nil {
+		return err
+	}
+	// No rebalance is started e.g. when it's disabled in the cluster config.
+	if rebID == "" {
+		return nil
+	}
+	return waitForXaction(ctx, proxyURL, aisapi.XactReqArgs{ID: rebID, Kind: aisapc.ActRebalance}, retryInterval)
+}
+
+// StartDecommissionTarget starts decommissioning the target `targetID` without waiting for the rebalance
+// migrating its data, returning the rebalance ID (empty if none was started). The target stays in the cluster map,
+// in maintenance, until the rebalance finishes.
+func StartDecommissionTarget(ctx context.Context, proxyURL, targetID string) (rebID string, err error) {
+	params, err := aisBaseParams(ctx, proxyURL)
+	if err != nil {
+		return "", err
+	}
+	rebID, err = aisapi.DecommissionNode(params, &aisapc.ActValRmNode{DaemonID: targetID, RmUserData: true})
+	if err != nil {
+		return "", fmt.Errorf("failed to decommission target %q: %w", targetID, err)
+	}
+	return rebID, nil
+}
+
+// EnsureTargetEvacuated returns ErrTargetNotEvacuated (wrapped) unless the target `targetID` has been decommissioned,
+// i.e. removed from the cluster map, and the rebalance migrating its data has finished. It guards the deletion of
+// the target's PVCs. As the rebalance isn't tied to a single target, any running rebalance fails the check.
+func EnsureTargetEvacuated(ctx context.Context, proxyURL, targetID string) error {
+	smap, err := GetSmap(ctx, proxyURL)
+	if err != nil {
+		return err
+	}
+	if smap.GetTarget(targetID) != nil {
+		return fmt.Errorf("%w: target %q is still in the cluster map", ErrTargetNotEvacuated, targetID)
+	}
+	running, pct, err := GetRebalanceStatus(ctx, proxyURL)
+	if err != nil {
+		return err
+	}
+	if running {
+		return fmt.Errorf("%w: rebalance still running (%d%%)", ErrTargetNotEvacuated, pct)
+	}
+	return nil
+}
+
+// XactionStatus is the cluster-wide status of the latest xaction (job) of a kind, aggregated over the targets.
+// As with GetRebalanceStatus, the progress is the share of targets which have finished the xaction.
+type XactionStatus struct {
+	ID       string
+	Running  bool // still running on any target
+	Finished bool // finished on all targets
+	Aborted  bool // aborted on any target
+	Pct      int  // percentage of targets that finished
+}
+
+// GetXactionStatus queries the status of the latest xaction of the kind (e.g. `apc.ActRebalance`, `apc.ActResilver`,
+// `apc.ActECEncode`, `apc.ActLRU`). If no such xaction has run, it's reported as finished with 100%.
+func GetXactionStatus(ctx context.Context, proxyURL, kind string) (*XactionStatus, error) {
+	params, err := aisBaseParams(ctx, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	snaps, err := aisapi.QueryXactionSnaps(params, aisapi.XactReqArgs{Kind: kind})
+	if err != nil {
+		return nil, err
+	}
+
+	// Each target keeps the snaps of past xactions, find the latest one.
+	var latest *aisxact.SnapExt
+	for _, targetSnaps := range snaps {
+		for _, snap := range targetSnaps {
+			if latest == nil || snap.StartTime.After(latest.StartTime) {
+				latest = snap
+			}
+		}
+	}
+	if latest == nil {
+		return &XactionStatus{Finished: true, Pct: 100}, nil
+	}
+
+	status := &XactionStatus{ID: latest.ID}
+	var total, finished int
+	for _, targetSnaps := range snaps {
+		for _, snap := range targetSnaps {
+			if snap.ID != latest.ID {
+				continue
+			}
+			total++
+			if snap.Finished() {
+				finished++
+			}
+			status.Aborted = status.Aborted || snap.IsAborted()
+		}
+	}
+	status.Running = finished < total
+	status.Finished = !status.Running
+	status.Pct = finished * 100 / total
+	return status, nil
+}
+
+// GetRebalanceStatus reports whether the latest global rebalance is still running, and its progress
+// as the percentage of targets which have finished it. AIS doesn't know the total amount of data
+// to be moved up front, so per-target completion is the only progress measure available.
+// If no rebalance has ever run, it reports it as not running and 100% complete.
+func GetRebalanceStatus(ctx context.Context, proxyURL string) (running bool, pct int, err error) {
+	status, err := GetXactionStatus(ctx, proxyURL, aisapc.ActRebalance)
+	if err != nil {
+		return
+	}
+	return status.Running, status.Pct, nil
+}
+
+// waitForXaction polls the status of the xaction until it finishes, and fails if the xaction was aborted.
+func waitForXaction(ctx context.Context, proxyURL string, args aisapi.XactReqArgs, retryInterval time.Duration) error {
+	for {
+		params, err := aisBaseParams(ctx, proxyURL)
+		if err != nil {
+			return err
+		}
+		status, err := aisapi.GetXactionStatus(params, args)
+		if err != nil {
+			return err
+		}
+		if status.Finished() {
+			if status.Aborted() {
+				return fmt.Errorf("xaction %s[%s] aborted: %s", args.Kind, args.ID, status.ErrMsg)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("xaction %s[%s] hasn't finished: %w", args.Kind, args.ID, ctx.Err())
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// RestartTarget restarts a single target pod without a full rollout. The target is put into maintenance first and
+// the rebalance migrating its data is waited for, so that no data becomes unavailable while the pod is down.
+// Once the statefulset recreates the pod, the maintenance is stopped and the target rejoins the cluster.
+func (c *K8sClient) RestartTarget(ctx context.Context, proxyURL string, name types.NamespacedName, timeout time.Duration) error {
+	ctxBack, cancel := context.WithTimeout(ctx, c.waitTimeout(timeout))
+	defer cancel()
+	pod, err := c.GetPodByName(ctxBack, name)
+	if err != nil {
+		return err
+	}
+	smap, err := GetSmap(ctxBack, proxyURL)
+	if err != nil {
+		return err
+	}
+	node := findTargetByPodName(smap, name.Name)
+	if node == nil {
+		return fmt.Errorf("target of pod %q not found in the cluster map", name)
+	}
+
+	if !smap.PresentInMaint(node) {
+		params, err := aisBaseParams(ctxBack, proxyURL)
+		if err != nil {
+			return err
+		}
+		rebID, err := aisapi.StartMaintenance(params, &aisapc.ActValRmNode{DaemonID: node.ID()})
+		if err != nil {
+			return fmt.Errorf("failed to start maintenance of target %q: %w", node.ID(), err)
+		}
+		if rebID != "" {
+			args := aisapi.XactReqArgs{ID: rebID, Kind: aisapc.ActRebalance}
+			if err = waitForXaction(ctxBack, proxyURL, args, c.pollInterval()); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = c.DeletePodIfExists(ctxBack, nil, name); err != nil {
+		return err
+	}
+	err = c.waitForPod(ctxBack, name, timeout, c.pollInterval(), func(recreated *corev1.Pod) bool {
+		return recreated.UID != pod.UID && recreated.Status.Phase == corev1.PodRunning
+	})
+	if err != nil {
+		return fmt.Errorf("pod %q not recreated: %w", name, err)
+	}
+
+	params, err := aisBaseParams(ctxBack, proxyURL)
+	if err != nil {
+		return err
+	}
+	_, err = aisapi.StopMaintenance(params, &aisapc.ActValRmNode{DaemonID: node.ID()})
+	return err
+}
+
+// ReplaceMountpathPVC replaces the PVC backing a target mountpath, e.g. after a disk failure. The mountpath is detached
+// from the target first (resilvering the data onto the other mountpaths), then the PVC is deleted along with the pod
+// (the PVC is protected from deletion while in use), so that the statefulset recreates both and the PVC gets provisioned
+// anew. Once the new PVC is bound and the target is ready, the mountpath is attached back, triggering a resilver.
+func (c *K8sClient) ReplaceMountpathPVC(ctx context.Context, proxyURL string, podName types.NamespacedName, pvcName string,
+	timeout time.Duration) error {
+	return c.replaceMountpathPVC(ctx, proxyURL, podName, pvcName, timeout, c.pollInterval())
+}
+
+func (c *K8sClient) replaceMountpathPVC(ctx context.Context, proxyURL string, podName types.NamespacedName, pvcName string,
+	timeout, retryInterval time.Duration) error {
+	ctxBack, cancel := context.WithTimeout(ctx, c.waitTimeout(timeout))
+	defer cancel()
+	pod, err := c.GetPodByName(ctxBack, podName)
+	if err != nil {
+		return err
+	}
+	mountpath := pvcMountPath(pod, pvcName)
+	if mountpath == "" {
+		return fmt.Errorf("PVC %q is not mounted in pod %q", pvcName, podName)
+	}
+	pvc, err := c.GetPVCByName(ctxBack, types.NamespacedName{Name: pvcName, Namespace: podName.Namespace})
+	if err != nil {
+		return err
+	}
+
+	node, err := getTargetByPodName(ctxBack, proxyURL, podName.Name)
+	if err != nil {
+		return err
+	}
+	params, err := aisBaseParams(ctxBack, proxyURL)
+	if err != nil {
+		return err
+	}
+	if err = aisapi.DetachMountpath(params, node, mountpath, false /*dontResilver*/); err != nil {
+		return fmt.Errorf("failed to detach mountpath %q of target %q: %w", mountpath, node.ID(), err)
+	}
+
+	if _, err = c.DeleteResourceIfExists(ctxBack, nil, pvc); err != nil {
+		return err
+	}
+	if err = c.DeletePodIfExists(ctxBack, nil, podName); err != nil {
+		return err
+	}
+	if err = c.waitForPVCReplaced(ctxBack, pvc, retryInterval); err != nil {
+		return err
+	}
+	err = c.waitForPod(ctxBack, podName, timeout, retryInterval, func(recreated *corev1.Pod) bool {
+		return recreated.UID != pod.UID && isPodReady(recreated)
+	})
+	if err != nil {
+		return fmt.Errorf("pod %q not recreated: %w", podName, err)
+	}
+
+	// The target's public URL changes with the pod, so it's looked up again.
+	if node, err = getTargetByPodName(ctxBack, proxyURL, podName.Name); err != nil {
+		return err
+	}
+	if params, err = aisBaseParams(ctxBack, proxyURL); err != nil {
+		return err
+	}
+	if err = aisapi.AttachMountpath(params, node, mountpath, false /*force*/); err != nil {
+		return fmt.Errorf("failed to attach mountpath %q of target %q: %w", mountpath, node.ID(), err)
+	}
+	return nil
+}
+
+// waitForPVCReplaced waits until a new PVC (with a different UID) with the same name as `old` is bound.
+func (c *K8sClient) waitForPVCReplaced(ctx context.Context, old *corev1.PersistentVolumeClaim, retryInterval time.Duration) error {
+	name := types.NamespacedName{Name: old.Name, Namespace: old.Namespace}
+	for {
+		pvc, err := c.GetPVCByName(ctx, name)
+		if err == nil {
+			if pvc.UID != old.UID && pvc.Status.Phase == corev1.ClaimBound {
+				return nil
+			}
+		} else if !apierrors.IsNotFound(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("PVC %q not replaced: %w", name, ctx.Err())
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// pvcMountPath returns the path at which the PVC is mounted in the pod's containers, if any.
+func pvcMountPath(pod *corev1.Pod, pvcName string) string {
+	for _, vol := range pod.Spec.Volumes {
+		if vol.PersistentVolumeClaim == nil || vol.PersistentVolumeClaim.ClaimName != pvcName {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			for _, mount := range container.VolumeMounts {
+				if mount.Name == vol.Name {
+					return mount.MountPath
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// DeleteEvacuatedPVCsIfExist works as DeleteAllPVCsIfExist, but refuses to delete any PVC, returning
+// ErrTargetNotEvacuated (wrapped), while some of them belong to targets still present in the cluster map.
+// Unlike DeleteAllPVCsIfExist it requires a running cluster, so it can't be used once the cluster is shut down.
+func (c *K8sClient) DeleteEvacuatedPVCsIfExist(ctx context.Context, owner *aisv1.AIStore, proxyURL, namespace string,
+	labels client.MatchingLabels, opts ...client.ListOption) (anyExisted bool, err error) {
+	smap, err := GetSmap(ctx, proxyURL)
+	if err != nil {
+		return
+	}
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	err = c.client.List(ctx, pvcs, append([]client.ListOption{client.InNamespace(namespace), labels}, opts...)...)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			err = nil
+		}
+		return
+	}
+	for i := range pvcs.Items {
+		if node := findTargetByPVCName(smap, pvcs.Items[i].Name); node != nil {
+			err = fmt.Errorf("%w: PVC %q belongs to target %q still in the cluster map",
+				ErrTargetNotEvacuated, pvcs.Items[i].Name, node.ID())
+			return
+		}
+	}
+	return c.DeleteAllPVCsIfExist(ctx, owner, namespace, labels, opts...)
+}
+
+// getTargetByPodName looks up the target running in the pod in the current cluster map.
+func getTargetByPodName(ctx context.Context, proxyURL, podName string) (*aiscluster.Snode, error) {
+	smap, err := GetSmap(ctx, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	node := findTargetByPodName(smap, podName)
+	if node == nil {
+		return nil, fmt.Errorf("target of pod %q not found in the cluster map", podName)
+	}
+	return node, nil
+}
+
+// findTargetByPodName finds the target running in the pod, by matching its hostname.
+func findTargetByPodName(smap *aiscluster.Smap, podName string) *aiscluster.Snode {
+	for _, node := range smap.Tmap {
+		if nodePodName(node) == podName {
+			return node
+		}
+	}
+	return nil
+}
+
+// nodePodName returns the name of the pod running the node, i.e. the first label of its (FQDN) hostname.
+func nodePodName(node *aiscluster.Snode) string {
+	return strings.SplitN(node.IntraControlNet.NodeHostname, ".", 2)[0]
+}
+
+// findTargetByPVCName finds the target whose pod uses the statefulset PVC, named `<template>-<pod>`.
+func findTargetByPVCName(smap *aiscluster.Smap, pvcName string) *aiscluster.Snode {
+	for _, node := range smap.Tmap {
+		if podName := nodePodName(node); podName != "" && strings.HasSuffix(pvcName, "-"+podName) {
+			return node
+		}
+	}
+	return nil
+}
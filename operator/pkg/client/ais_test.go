@@ -0,0 +1,658 @@
+// Package client contains wrapper for k8s client
+/*
+ * Copyright (c) 2021, NVIDIA CORPORATION. All rights reserved.
+ */
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	aisapi "github.com/NVIDIA/aistore/api"
+	aisapc "github.com/NVIDIA/aistore/api/apc"
+	aiscluster "github.com/NVIDIA/aistore/cluster"
+	aiscmn "github.com/NVIDIA/aistore/cmn"
+	aiscos "github.com/NVIDIA/aistore/cmn/cos"
+	"github.com/NVIDIA/aistore/nl"
+	aisxact "github.com/NVIDIA/aistore/xact"
+	aisv1 "github.com/ais-operator/api/v1beta1"
+)
+
+var _ = Describe("AIS API", func() {
+	var (
+		ctx    context.Context
+		server *httptest.Server
+		mux    *http.ServeMux
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		mux = http.NewServeMux()
+		server = httptest.NewServer(mux)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("CheckAISHealth", func() {
+		It("should succeed when proxy is healthy", func() {
+			mux.HandleFunc("/v1/health", func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			Expect(CheckAISHealth(ctx, server.URL)).To(Succeed())
+		})
+
+		It("should fail when proxy is not healthy", func() {
+			mux.HandleFunc("/v1/health", func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			})
+			Expect(CheckAISHealth(ctx, server.URL)).NotTo(Succeed())
+		})
+	})
+
+	Describe("WaitForClusterQuorum", func() {
+		It("should wait for primary and stable smap", func() {
+			version := 0
+			mux.HandleFunc("/v1/daemon", func(w http.ResponseWriter, _ *http.Request) {
+				if version < 3 {
+					version++
+				}
+				smap := &aiscluster.Smap{Version: int64(version)}
+				if version > 1 {
+					smap.Primary = &aiscluster.Snode{DaemonID: "p1"}
+				}
+				Expect(json.NewEncoder(w).Encode(smap)).To(Succeed())
+			})
+			Expect(waitForClusterQuorum(ctx, server.URL, 5*time.Second, 10*time.Millisecond)).To(Succeed())
+			Expect(version).To(Equal(3))
+		})
+	})
+
+	Describe("GetSmap", func() {
+		It("should parse the cluster map", func() {
+			mux.HandleFunc("/v1/daemon", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get("what")).To(Equal("smap"))
+				smap := &aiscluster.Smap{
+					Version: 7,
+					Primary: &aiscluster.Snode{DaemonID: "p1"},
+					Tmap:    aiscluster.NodeMap{"t1": {DaemonID: "t1"}, "t2": {DaemonID: "t2"}},
+				}
+				Expect(json.NewEncoder(w).Encode(smap)).To(Succeed())
+			})
+			smap, err := GetSmap(ctx, server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(smap.Version).To(BeEquivalentTo(7))
+			Expect(smap.Primary.ID()).To(Equal("p1"))
+			Expect(smap.CountTargets()).To(Equal(2))
+		})
+	})
+
+	Describe("SetPrimaryProxy", func() {
+		var setPrimary []string
+
+		BeforeEach(func() {
+			setPrimary = nil
+			mux.HandleFunc("/v1/daemon", func(w http.ResponseWriter, _ *http.Request) {
+				smap := &aiscluster.Smap{Version: 2, Primary: &aiscluster.Snode{DaemonID: "p1"}}
+				Expect(json.NewEncoder(w).Encode(smap)).To(Succeed())
+			})
+			mux.HandleFunc("/v1/cluster/proxy/", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Method).To(Equal(http.MethodPut))
+				Expect(r.URL.Query().Get(aisapc.QparamForce)).To(Equal("false"))
+				setPrimary = append(setPrimary, strings.TrimPrefix(r.URL.Path, "/v1/cluster/proxy/"))
+			})
+		})
+
+		It("should get the primary proxy", func() {
+			nodeID, err := GetPrimaryProxy(ctx, server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nodeID).To(Equal("p1"))
+		})
+
+		It("should set the primary proxy unless already primary", func() {
+			Expect(SetPrimaryProxy(ctx, server.URL, "p1")).To(Succeed())
+			Expect(setPrimary).To(BeEmpty())
+			Expect(SetPrimaryProxy(ctx, server.URL, "p2")).To(Succeed())
+			Expect(setPrimary).To(Equal([]string{"p2"}))
+		})
+	})
+
+	Describe("ListBuckets", func() {
+		It("should list the AIS bucket names", func() {
+			mux.HandleFunc("/v1/buckets", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get("provider")).To(Equal(aisapc.ProviderAIS))
+				bcks := aiscmn.Bcks{{Name: "b1", Provider: aisapc.ProviderAIS}, {Name: "b2", Provider: aisapc.ProviderAIS}}
+				Expect(json.NewEncoder(w).Encode(bcks)).To(Succeed())
+			})
+			names, err := ListBuckets(ctx, server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names).To(Equal([]string{"b1", "b2"}))
+		})
+	})
+
+	Describe("GetClusterStats", func() {
+		It("should sum the mountpath capacity per target and cluster-wide", func() {
+			mux.HandleFunc("/v1/cluster", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get(aisapc.QparamWhat)).To(Equal(aisapc.GetWhatStats))
+				_, err := w.Write([]byte(`{"target": {
+					"t1": {"capacity": {"/mp1": {"used": "10", "avail": "90"}, "/mp2": {"used": "30", "avail": "70"}}},
+					"t2": {"capacity": {"/mp1": {"used": "50", "avail": "50"}}}
+				}}`))
+				Expect(err).NotTo(HaveOccurred())
+			})
+			stats, err := GetClusterStats(ctx, server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stats.TotalBytes).To(BeEquivalentTo(300))
+			Expect(stats.UsedBytes).To(BeEquivalentTo(90))
+			Expect(stats.Targets).To(Equal(map[string]TargetCapacity{
+				"t1": {TotalBytes: 200, UsedBytes: 40},
+				"t2": {TotalBytes: 100, UsedBytes: 50},
+			}))
+		})
+	})
+
+	Describe("BackupClusterMetadata", func() {
+		newBMD := func(names ...string) *aiscluster.BMD {
+			bmd := &aiscluster.BMD{Version: 2, Providers: aiscluster.Providers{}}
+			for _, name := range names {
+				bmd.Add(aiscluster.NewBck(name, aisapc.ProviderAIS, aiscmn.NsGlobal, &aiscmn.BucketProps{}))
+			}
+			return bmd
+		}
+
+		It("should serialize the Smap and BMD", func() {
+			mux.HandleFunc("/v1/daemon", func(w http.ResponseWriter, _ *http.Request) {
+				smap := &aiscluster.Smap{Version: 3, Primary: &aiscluster.Snode{DaemonID: "p1"}}
+				Expect(json.NewEncoder(w).Encode(smap)).To(Succeed())
+			})
+			mux.HandleFunc("/v1/cluster", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get(aisapc.QparamWhat)).To(Equal(aisapc.GetWhatBMD))
+				Expect(json.NewEncoder(w).Encode(newBMD("b1"))).To(Succeed())
+			})
+			data, err := BackupClusterMetadata(ctx, server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			snapshot := ClusterMetadataSnapshot{}
+			Expect(json.Unmarshal(data, &snapshot)).To(Succeed())
+			Expect(snapshot.Smap.Primary.DaemonID).To(Equal("p1"))
+			Expect(snapshot.BMD.Version).To(BeEquivalentTo(2))
+			Expect(snapshot.BMD.Providers[aisapc.ProviderAIS][aiscmn.NsGlobal.Uname()]).To(HaveKey("b1"))
+		})
+
+		It("should recreate only the missing buckets on restore", func() {
+			data, err := json.Marshal(ClusterMetadataSnapshot{BMD: newBMD("b1", "b2")})
+			Expect(err).NotTo(HaveOccurred())
+			mux.HandleFunc("/v1/cluster", func(w http.ResponseWriter, _ *http.Request) {
+				Expect(json.NewEncoder(w).Encode(newBMD("b1"))).To(Succeed())
+			})
+			var created []string
+			mux.HandleFunc("/v1/buckets/", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Method).To(Equal(http.MethodPost))
+				created = append(created, strings.TrimPrefix(r.URL.Path, "/v1/buckets/"))
+			})
+			restored, err := RestoreClusterMetadata(ctx, server.URL, data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restored).To(Equal([]string{"ais://b2"}))
+			Expect(created).To(Equal([]string{"b2"}))
+		})
+
+		It("should reject a snapshot without BMD", func() {
+			_, err := RestoreClusterMetadata(ctx, server.URL, []byte(`{}`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("CheckProxyConsensus", func() {
+		var other *httptest.Server
+
+		serveSmap := func(mux *http.ServeMux, primaryID string) {
+			mux.HandleFunc("/v1/daemon", func(w http.ResponseWriter, _ *http.Request) {
+				smap := &aiscluster.Smap{Version: 3, Primary: &aiscluster.Snode{DaemonID: primaryID}}
+				Expect(json.NewEncoder(w).Encode(smap)).To(Succeed())
+			})
+		}
+
+		AfterEach(func() {
+			other.Close()
+		})
+
+		It("should succeed when the proxies agree on the primary", func() {
+			otherMux := http.NewServeMux()
+			other = httptest.NewServer(otherMux)
+			serveSmap(mux, "p1")
+			serveSmap(otherMux, "p1")
+			Expect(CheckProxyConsensus(ctx, []string{server.URL, other.URL})).To(Succeed())
+		})
+
+		It("should fail when the proxies disagree on the primary", func() {
+			otherMux := http.NewServeMux()
+			other = httptest.NewServer(otherMux)
+			serveSmap(mux, "p1")
+			serveSmap(otherMux, "p2")
+			err := CheckProxyConsensus(ctx, []string{server.URL, other.URL})
+			Expect(errors.Is(err, ErrNoProxyConsensus)).To(BeTrue())
+		})
+
+		It("should ignore unreachable proxies", func() {
+			other = httptest.NewServer(http.NotFoundHandler())
+			serveSmap(mux, "p1")
+			Expect(CheckProxyConsensus(ctx, []string{server.URL, other.URL})).To(Succeed())
+			Expect(CheckProxyConsensus(ctx, []string{other.URL})).NotTo(Succeed())
+		})
+	})
+
+	Describe("DecommissionTarget", func() {
+		var polls, finishAfter int
+
+		BeforeEach(func() {
+			polls, finishAfter = 0, 2
+			mux.HandleFunc("/v1/cluster", func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case http.MethodPut:
+					msg := &aisapc.ActionMsg{}
+					Expect(json.NewDecoder(r.Body).Decode(msg)).To(Succeed())
+					Expect(msg.Action).To(Equal(aisapc.ActDecommissionNode))
+					w.Write([]byte("reb-1"))
+				case http.MethodGet:
+					polls++
+					status := &nl.NotifStatus{UUID: "reb-1"}
+					if polls >= finishAfter {
+						status.FinTime = time.Now().UnixNano()
+					}
+					Expect(json.NewEncoder(w).Encode(status)).To(Succeed())
+				}
+			})
+		})
+
+		It("should wait for the rebalance to finish", func() {
+			Expect(decommissionTarget(ctx, server.URL, "t1", 10*time.Millisecond)).To(Succeed())
+			Expect(polls).To(Equal(2))
+		})
+
+		It("should time out while the rebalance is running", func() {
+			finishAfter = math.MaxInt32
+			ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+			err := decommissionTarget(ctx, server.URL, "t1", 10*time.Millisecond)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("EnsureTargetEvacuated", func() {
+		serveSmap := func(targetIDs ...string) {
+			mux.HandleFunc("/v1/daemon", func(w http.ResponseWriter, _ *http.Request) {
+				smap := &aiscluster.Smap{Version: 1, Tmap: aiscluster.NodeMap{}}
+				for _, id := range targetIDs {
+					smap.Tmap[id] = &aiscluster.Snode{DaemonID: id, DaemonType: aisapc.Target}
+				}
+				Expect(json.NewEncoder(w).Encode(smap)).To(Succeed())
+			})
+		}
+		serveRebalance := func(finished bool) {
+			mux.HandleFunc("/v1/cluster", func(w http.ResponseWriter, _ *http.Request) {
+				snap := &aisxact.SnapExt{Snap: aisxact.Snap{ID: "reb-1", Kind: aisapc.ActRebalance, StartTime: time.Now()}}
+				if finished {
+					snap.EndTime = time.Now()
+				}
+				Expect(json.NewEncoder(w).Encode(aisapi.NodesXactMultiSnap{"t2": {snap}})).To(Succeed())
+			})
+		}
+
+		It("should succeed once the target is decommissioned and the rebalance finished", func() {
+			serveSmap("t2")
+			serveRebalance(true)
+			Expect(EnsureTargetEvacuated(ctx, server.URL, "t1")).To(Succeed())
+		})
+
+		It("should fail while the target is in the cluster map", func() {
+			serveSmap("t1", "t2")
+			serveRebalance(true)
+			err := EnsureTargetEvacuated(ctx, server.URL, "t1")
+			Expect(errors.Is(err, ErrTargetNotEvacuated)).To(BeTrue())
+		})
+
+		It("should fail while the rebalance is running", func() {
+			serveSmap("t2")
+			serveRebalance(false)
+			err := EnsureTargetEvacuated(ctx, server.URL, "t1")
+			Expect(errors.Is(err, ErrTargetNotEvacuated)).To(BeTrue())
+		})
+	})
+
+	Describe("GetRebalanceStatus", func() {
+		newSnap := func(id string, start time.Time, finished bool) *aisxact.SnapExt {
+			snap := &aisxact.SnapExt{Snap: aisxact.Snap{ID: id, Kind: aisapc.ActRebalance, StartTime: start}}
+			if finished {
+				snap.EndTime = start.Add(time.Minute)
+			}
+			return snap
+		}
+
+		serveSnaps := func(snaps aisapi.NodesXactMultiSnap) {
+			mux.HandleFunc("/v1/cluster", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get("what")).To(Equal("qryxstats"))
+				Expect(json.NewEncoder(w).Encode(snaps)).To(Succeed())
+			})
+		}
+
+		It("should report progress of the latest rebalance", func() {
+			earlier := time.Now().Add(-time.Hour)
+			now := time.Now()
+			serveSnaps(aisapi.NodesXactMultiSnap{
+				"t1": {newSnap("reb-1", earlier, true), newSnap("reb-2", now, true)},
+				"t2": {newSnap("reb-1", earlier, true), newSnap("reb-2", now, false)},
+			})
+			running, pct, err := GetRebalanceStatus(ctx, server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(running).To(BeTrue())
+			Expect(pct).To(Equal(50))
+		})
+
+		It("should report no rebalance when none has run", func() {
+			serveSnaps(aisapi.NodesXactMultiSnap{})
+			running, pct, err := GetRebalanceStatus(ctx, server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(running).To(BeFalse())
+			Expect(pct).To(Equal(100))
+		})
+	})
+
+	Describe("GetClusterHealth", func() {
+		It("should combine pod readiness with the cluster map and rebalance state", func() {
+			ais := &aisv1.AIStore{ObjectMeta: metav1.ObjectMeta{Name: "ais", Namespace: testNamespace}}
+			newPod := func(name, component string, ready bool) *corev1.Pod {
+				pod := newTestPod(name, corev1.PodRunning)
+				if ready {
+					pod = newReadyTestPod(name)
+				}
+				pod.Labels = map[string]string{"app": ais.Name, "component": component}
+				return pod
+			}
+			c, _ := newTestClient(newPod("ais-proxy-0", aisapc.Proxy, true), newPod("ais-target-0", aisapc.Target, true),
+				newPod("ais-target-1", aisapc.Target, false))
+			mux.HandleFunc("/v1/daemon", func(w http.ResponseWriter, _ *http.Request) {
+				smap := &aiscluster.Smap{Version: 3, Primary: &aiscluster.Snode{DaemonID: "p1"}}
+				Expect(json.NewEncoder(w).Encode(smap)).To(Succeed())
+			})
+			mux.HandleFunc("/v1/cluster", func(w http.ResponseWriter, _ *http.Request) {
+				reb := &aisxact.SnapExt{Snap: aisxact.Snap{ID: "reb-1", Kind: aisapc.ActRebalance, StartTime: time.Now()}}
+				Expect(json.NewEncoder(w).Encode(aisapi.NodesXactMultiSnap{"t1": {reb}})).To(Succeed())
+			})
+
+			health, err := c.GetClusterHealth(ctx, ais, server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*health).To(Equal(ClusterHealth{
+				ReadyProxies:   1,
+				ReadyTargets:   1,
+				PrimaryElected: true,
+				Rebalancing:    true,
+				DegradedPods:   []string{"ais-target-1"},
+			}))
+			Expect(health.IsHealthy(1)).To(BeFalse())
+		})
+	})
+
+	Describe("GetXactionStatus", func() {
+		It("should aggregate the status of the latest xaction of the kind", func() {
+			start := time.Now()
+			running := &aisxact.SnapExt{Snap: aisxact.Snap{ID: "res-1", Kind: aisapc.ActResilver, StartTime: start}}
+			aborted := &aisxact.SnapExt{Snap: aisxact.Snap{
+				ID: "res-1", Kind: aisapc.ActResilver, StartTime: start, EndTime: start.Add(time.Second), AbortedX: true,
+			}}
+			mux.HandleFunc("/v1/cluster", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get("what")).To(Equal("qryxstats"))
+				snaps := aisapi.NodesXactMultiSnap{"t1": {running}, "t2": {aborted}}
+				Expect(json.NewEncoder(w).Encode(snaps)).To(Succeed())
+			})
+			status, err := GetXactionStatus(ctx, server.URL, aisapc.ActResilver)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status.ID).To(Equal("res-1"))
+			Expect(status.Running).To(BeTrue())
+			Expect(status.Finished).To(BeFalse())
+			Expect(status.Aborted).To(BeTrue())
+			Expect(status.Pct).To(Equal(50))
+		})
+	})
+
+	Describe("TriggerConfigReload", func() {
+		It("should send the config update to the cluster", func() {
+			mux.HandleFunc("/v1/cluster", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Method).To(Equal(http.MethodPut))
+				msg := &aisapc.ActionMsg{}
+				Expect(json.NewDecoder(r.Body).Decode(msg)).To(Succeed())
+				Expect(msg.Action).To(Equal(aisapc.ActSetConfig))
+				Expect(msg.Value).To(HaveKeyWithValue("rebalance", HaveKeyWithValue("enabled", false)))
+			})
+			enabled := false
+			toUpdate := &aiscmn.ConfigToUpdate{Rebalance: &aiscmn.RebalanceConfToUpdate{Enabled: &enabled}}
+			Expect(TriggerConfigReload(ctx, server.URL, toUpdate)).To(Succeed())
+		})
+	})
+
+	Describe("ApplyClusterConfig", func() {
+		var setQuery url.Values
+
+		BeforeEach(func() {
+			setQuery = nil
+			mux.HandleFunc("/v1/cluster", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get("what")).To(Equal("cluster_config"))
+				config := &aiscmn.ClusterConfig{}
+				config.Rebalance.Enabled = true
+				config.Timeout.MaxHostBusy = aiscos.Duration(time.Minute)
+				Expect(json.NewEncoder(w).Encode(config)).To(Succeed())
+			})
+			mux.HandleFunc("/v1/cluster/set-config", func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Method).To(Equal(http.MethodPut))
+				setQuery = r.URL.Query()
+			})
+		})
+
+		It("should set only the values which differ", func() {
+			updated, err := ApplyClusterConfig(ctx, server.URL, map[string]string{
+				"rebalance.enabled": "true",
+				"mirror.enabled":    "true",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			Expect(setQuery).To(HaveLen(1))
+			Expect(setQuery.Get("mirror.enabled")).To(Equal("true"))
+		})
+
+		It("should compare the parsed values", func() {
+			updated, err := ApplyClusterConfig(ctx, server.URL, map[string]string{"timeout.max_host_busy": "1m"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+			Expect(setQuery).To(BeNil())
+
+			updated, err = ApplyClusterConfig(ctx, server.URL, map[string]string{"timeout.max_host_busy": "90s"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			Expect(setQuery.Get("timeout.max_host_busy")).To(Equal("90s"))
+		})
+
+		It("should fail on unknown config names", func() {
+			_, err := ApplyClusterConfig(ctx, server.URL, map[string]string{"rebalance.unknown": "true"})
+			Expect(err).To(HaveOccurred())
+			Expect(setQuery).To(BeNil())
+		})
+
+		It("should not set anything without a drift", func() {
+			updated, err := ApplyClusterConfig(ctx, server.URL, map[string]string{"rebalance.enabled": "true"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+			Expect(setQuery).To(BeNil())
+		})
+	})
+
+	Describe("RestartTarget", func() {
+		It("should restart the pod in maintenance", func() {
+			var actions []string
+			mux.HandleFunc("/v1/daemon", func(w http.ResponseWriter, _ *http.Request) {
+				smap := &aiscluster.Smap{
+					Version: 1,
+					Tmap: aiscluster.NodeMap{"t1": {
+						DaemonID:        "t1",
+						DaemonType:      aisapc.Target,
+						IntraControlNet: aiscluster.NetInfo{NodeHostname: "target-0.target.ais-test.svc"},
+					}},
+				}
+				Expect(json.NewEncoder(w).Encode(smap)).To(Succeed())
+			})
+			mux.HandleFunc("/v1/cluster", func(w http.ResponseWriter, r *http.Request) {
+				msg := &aisapc.ActionMsg{}
+				Expect(json.NewDecoder(r.Body).Decode(msg)).To(Succeed())
+				actions = append(actions, msg.Action)
+			})
+
+			pod := newReadyTestPod("target-0")
+			pod.UID = "old-uid"
+			c, ec := newTestClient(pod)
+			name := types.NamespacedName{Name: "target-0", Namespace: testNamespace}
+			go func() {
+				defer GinkgoRecover()
+				Eventually(func() bool {
+					_, err := c.GetPodByName(ctx, name)
+					return apierrors.IsNotFound(err)
+				}).Should(BeTrue())
+				recreated := newReadyTestPod("target-0")
+				recreated.UID = "new-uid"
+				Expect(ec.Client.Create(ctx, recreated)).To(Succeed())
+			}()
+
+			Expect(c.RestartTarget(ctx, server.URL, name, 10*time.Second)).To(Succeed())
+			Expect(actions).To(Equal([]string{aisapc.ActStartMaintenance, aisapc.ActStopMaintenance}))
+		})
+	})
+
+	Describe("findTargetByPodName", func() {
+		It("should match the pod name exactly", func() {
+			smap := &aiscluster.Smap{Tmap: aiscluster.NodeMap{}}
+			for id, hostname := range map[string]string{
+				"t1":  "target-1.target.ais-test.svc",
+				"t10": "target-10.target.ais-test.svc",
+			} {
+				smap.Tmap[id] = &aiscluster.Snode{DaemonID: id, IntraControlNet: aiscluster.NetInfo{NodeHostname: hostname}}
+			}
+			// The map iteration order is random, repeat to catch a prefix match.
+			for i := 0; i < 10; i++ {
+				Expect(findTargetByPodName(smap, "target-1").ID()).To(Equal("t1"))
+				Expect(findTargetByPodName(smap, "target-10").ID()).To(Equal("t10"))
+			}
+			Expect(findTargetByPodName(smap, "target-0")).To(BeNil())
+		})
+	})
+
+	Describe("ReplaceMountpathPVC", func() {
+		It("should detach the mountpath, recreate the PVC and attach it back", func() {
+			var actions []string
+			mux.HandleFunc("/v1/daemon", func(w http.ResponseWriter, _ *http.Request) {
+				smap := &aiscluster.Smap{
+					Version: 1,
+					Tmap: aiscluster.NodeMap{"t1": {
+						DaemonID:        "t1",
+						DaemonType:      aisapc.Target,
+						IntraControlNet: aiscluster.NetInfo{NodeHostname: "target-0.target.ais-test.svc"},
+					}},
+				}
+				Expect(json.NewEncoder(w).Encode(smap)).To(Succeed())
+			})
+			mux.HandleFunc("/v1/reverse/daemon/mountpaths", func(w http.ResponseWriter, r *http.Request) {
+				msg := &aisapc.ActionMsg{}
+				Expect(json.NewDecoder(r.Body).Decode(msg)).To(Succeed())
+				Expect(msg.Value).To(Equal("/ais/disk1"))
+				actions = append(actions, msg.Action)
+			})
+
+			newPod := func(uid types.UID) *corev1.Pod {
+				pod := newReadyTestPod("target-0")
+				pod.UID = uid
+				pod.Spec.Volumes = []corev1.Volume{{
+					Name: "disk1",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "disk1-target-0"},
+					},
+				}}
+				pod.Spec.Containers = []corev1.Container{{
+					Name:         "ais-node",
+					VolumeMounts: []corev1.VolumeMount{{Name: "disk1", MountPath: "/ais/disk1"}},
+				}}
+				return pod
+			}
+			newPVC := func(uid types.UID) *corev1.PersistentVolumeClaim {
+				return &corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{Name: "disk1-target-0", Namespace: testNamespace, UID: uid},
+					Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+				}
+			}
+			c, ec := newTestClient(newPod("old-pod"), newPVC("old-pvc"))
+			name := types.NamespacedName{Name: "target-0", Namespace: testNamespace}
+			go func() {
+				defer GinkgoRecover()
+				Eventually(func() bool {
+					_, err := c.GetPodByName(ctx, name)
+					return apierrors.IsNotFound(err)
+				}).Should(BeTrue())
+				Expect(ec.Client.Create(ctx, newPVC("new-pvc"))).To(Succeed())
+				Expect(ec.Client.Create(ctx, newPod("new-pod"))).To(Succeed())
+			}()
+
+			err := c.replaceMountpathPVC(ctx, server.URL, name, "disk1-target-0", 10*time.Second, 10*time.Millisecond)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(actions).To(Equal([]string{aisapc.ActMountpathDetach, aisapc.ActMountpathAttach}))
+		})
+	})
+
+	Describe("DeleteEvacuatedPVCsIfExist", func() {
+		labels := map[string]string{"app": "ais"}
+		newPVC := func(name string) *corev1.PersistentVolumeClaim {
+			return &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, Labels: labels},
+			}
+		}
+		serveSmap := func(hostname string) {
+			mux.HandleFunc("/v1/daemon", func(w http.ResponseWriter, _ *http.Request) {
+				smap := &aiscluster.Smap{Version: 1, Tmap: aiscluster.NodeMap{"t1": {
+					DaemonID:        "t1",
+					DaemonType:      aisapc.Target,
+					IntraControlNet: aiscluster.NetInfo{NodeHostname: hostname},
+				}}}
+				Expect(json.NewEncoder(w).Encode(smap)).To(Succeed())
+			})
+		}
+
+		It("should refuse to delete PVCs of targets in the cluster map", func() {
+			serveSmap("target-1.target.ais-test.svc")
+			c, _ := newTestClient(newPVC("disk1-target-0"), newPVC("disk1-target-1"))
+			_, err := c.DeleteEvacuatedPVCsIfExist(ctx, nil, server.URL, testNamespace, labels)
+			Expect(errors.Is(err, ErrTargetNotEvacuated)).To(BeTrue())
+			pvcs := &corev1.PersistentVolumeClaimList{}
+			Expect(c.client.List(ctx, pvcs)).To(Succeed())
+			Expect(pvcs.Items).To(HaveLen(2))
+		})
+
+		It("should delete PVCs of decommissioned targets", func() {
+			serveSmap("target-11.target.ais-test.svc")
+			c, _ := newTestClient(newPVC("disk1-target-0"), newPVC("disk1-target-1"))
+			existed, err := c.DeleteEvacuatedPVCsIfExist(ctx, nil, server.URL, testNamespace, labels)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(existed).To(BeTrue())
+			pvcs := &corev1.PersistentVolumeClaimList{}
+			Expect(c.client.List(ctx, pvcs)).To(Succeed())
+			Expect(pvcs.Items).To(BeEmpty())
+		})
+	})
+})
@@ -5,35 +5,212 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/go-logr/logr"
 	apiv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	aisv1 "github.com/ais-operator/api/v1beta1"
 )
 
+const (
+	DefaultRetryInterval = 3 * time.Second
+	MaxRetryInterval     = 30 * time.Second
+	DefaultWaitTimeout   = 5 * time.Minute
+	// ValidationTimeout bounds the reads made on behalf of admission webhooks (see GetAIStoreForValidation),
+	// well within the API server's webhook timeout.
+	ValidationTimeout = 2 * time.Second
+
+	// AnnotationMaintenance is set on AIS target pods that should stop accepting new requests and drain.
+	AnnotationMaintenance = "ais.nvidia.com/maintenance"
+
+	// AnnotationTLSSecretVersion is stamped on the statefulset (and its pod template, once restarted) with
+	// the `resourceVersion` of the TLS certificate Secret the pods were started with (see RestartStatefulSetOnSecretChange).
+	AnnotationTLSSecretVersion = "ais.nvidia.com/tls-secret-version"
+
+	// AnnotationTopologyLock is set on the AIStore CR to the disruptive operation (e.g. scale, upgrade)
+	// currently holding the topology lock, and AnnotationTopologyLockTime to when it was acquired.
+	AnnotationTopologyLock     = "ais.nvidia.com/topology-lock"
+	AnnotationTopologyLockTime = "ais.nvidia.com/topology-lock-time"
+	// TopologyLockTTL is how long the topology lock is held before it can be taken over, e.g. if the operator
+	// crashed in the middle of an operation.
+	TopologyLockTTL = time.Hour
+
+	// AnnotationPaused set to "true" on the AIStore CR stops the reconciler from acting on the cluster,
+	// e.g. so that manual changes made while debugging aren't reverted. Deletion of the CR is still handled.
+	AnnotationPaused = "ais.nvidia.com/paused"
+
+	// AnnotationSpecHash is stamped by CreateOrUpdate on the managed resources (see ComputeSpecHash).
+	AnnotationSpecHash = "ais.nvidia.com/spec-hash"
+)
+
 type (
+	// K8sClient wraps the controller-runtime client with AIS specific helpers.
+	//
+	// When created with NewClientFromMgr, reads (`Get*`, `List*`, `*Exists`) are served from the manager's
+	// informer cache and may lag behind the API server. Use the `*Live` variants (e.g. GetStatefulSetLive)
+	// where acting on a stale object is unsafe, e.g. scaling decisions. Writes always go to the API server.
 	K8sClient struct {
-		client client.Client
-		scheme *runtime.Scheme
+		client    client.Client
+		apiReader client.Reader        // uncached reads, straight from the API server
+		clientset kubernetes.Interface // for subresources not supported by controller-runtime client, e.g. pod logs
+		config    *rest.Config
+		scheme    *runtime.Scheme
+		recorder  record.EventRecorder
+		opts      ClientOptions
+	}
+
+	// ClientOptions holds the defaults of the K8sClient wait helpers (`WaitFor*`, `RestartTarget`, ...),
+	// e.g. to allow longer timeouts for large clusters. A non-zero timeout passed to a helper overrides the default.
+	ClientOptions struct {
+		// DefaultWaitTimeout is used when a helper is called with a zero timeout, `DefaultWaitTimeout` if not set.
+		DefaultWaitTimeout time.Duration
+		// PollInterval is the (initial) interval between polls, `DefaultRetryInterval` if not set.
+		PollInterval time.Duration
+		// Logger logs the K8s API calls made by the client (writes at V(1), failures at the default level).
+		// Defaults to the manager's logger.
+		Logger logr.Logger
+	}
+
+	// PVCMismatch describes a statefulset PVC bound to a PV that claims another PVC (see MatchPVCsToOrdinals).
+	PVCMismatch struct {
+		PVC       string // name of the PVC, i.e. `<template>-<statefulset>-<ordinal>`
+		PV        string // name of the PV the PVC is bound to
+		ClaimedBy string // PVC (`<namespace>/<name>`) referenced by the PV claim, empty if none
 	}
 )
 
-func NewClientFromMgr(mgr manager.Manager) *K8sClient {
+func NewClientFromMgr(mgr manager.Manager, opts ClientOptions) *K8sClient {
+	if opts.Logger.GetSink() == nil {
+		opts.Logger = mgr.GetLogger().WithName("client")
+	}
+	return &K8sClient{
+		client:    newInstrumentedClient(mgr.GetClient(), opts.Logger),
+		apiReader: mgr.GetAPIReader(),
+		clientset: kubernetes.NewForConfigOrDie(mgr.GetConfig()),
+		config:    mgr.GetConfig(),
+		scheme:    mgr.GetScheme(),
+		recorder:  mgr.GetEventRecorderFor("ais-controller"),
+		opts:      opts,
+	}
+}
+
+// NewClient wraps an existing controller-runtime client, e.g. a fake one in tests. Uncached reads go through
+// the same client; subresources served by the clientset (e.g. pod logs) and events aren't available.
+func NewClient(c client.Client, scheme *runtime.Scheme, opts ClientOptions) *K8sClient {
+	if opts.Logger.GetSink() == nil {
+		opts.Logger = logf.Log.WithName("client")
+	}
 	return &K8sClient{
-		client: mgr.GetClient(),
-		scheme: mgr.GetScheme(),
+		client:    newInstrumentedClient(c, opts.Logger),
+		apiReader: c,
+		scheme:    scheme,
+		opts:      opts,
+	}
+}
+
+func (c *K8sClient) waitTimeout(timeout time.Duration) time.Duration {
+	switch {
+	case timeout > 0:
+		return timeout
+	case c.opts.DefaultWaitTimeout > 0:
+		return c.opts.DefaultWaitTimeout
+	default:
+		return DefaultWaitTimeout
+	}
+}
+
+func (c *K8sClient) pollInterval() time.Duration {
+	if c.opts.PollInterval > 0 {
+		return c.opts.PollInterval
+	}
+	return DefaultRetryInterval
+}
+
+// retryInterval returns `interval`, or the client poll interval if it isn't positive, to avoid busy polling.
+func (c *K8sClient) retryInterval(interval time.Duration) time.Duration {
+	if interval > 0 {
+		return interval
+	}
+	return c.pollInterval()
+}
+
+// logger returns the client logger, falling back to the global one for clients created without it.
+func (c *K8sClient) logger() logr.Logger {
+	if c.opts.Logger.GetSink() == nil {
+		return logf.Log.WithName("client")
+	}
+	return c.opts.Logger
+}
+
+// NewClientFromConfig creates a client for the (possibly remote) cluster at the REST config endpoint,
+// e.g. loaded from a kubeconfig. Unlike NewClientFromMgr, reads aren't cached and events aren't recorded.
+func NewClientFromConfig(config *rest.Config) (*K8sClient, error) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
 	}
+	if err := aisv1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	logger := logf.Log.WithName("client")
+	return &K8sClient{
+		client:    newInstrumentedClient(c, logger),
+		apiReader: c,
+		clientset: clientset,
+		config:    config,
+		scheme:    scheme,
+		opts:      ClientOptions{Logger: logger},
+	}, nil
 }
 
 /////////////////////////////////////////
@@ -48,10 +225,36 @@ func (c *K8sClient) Get(ctx context.Context, key client.ObjectKey, obj client.Ob
 	return c.client.Get(ctx, key, obj)
 }
 
+// GetByGVK fetches an object of any kind, e.g. one-off kinds without a typed getter (PodDisruptionBudget, NetworkPolicy).
+// If the kind is registered in the client's scheme a typed object is returned, otherwise an unstructured one.
+func (c *K8sClient) GetByGVK(ctx context.Context, name types.NamespacedName, gvk schema.GroupVersionKind) (client.Object, error) {
+	var obj client.Object
+	if typed, err := c.scheme.New(gvk); err == nil {
+		obj, _ = typed.(client.Object)
+	}
+	if obj == nil {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(gvk)
+		obj = u
+	}
+	err := c.client.Get(ctx, name, obj)
+	return obj, err
+}
+
 func (c *K8sClient) GetAIStoreCR(ctx context.Context, name types.NamespacedName) (*aisv1.AIStore, error) {
 	aistore := &aisv1.AIStore{}
 	err := c.client.Get(ctx, name, aistore)
-	return aistore, err
+	return aistore, wrapNotFound(err, ErrAIStoreNotFound)
+}
+
+// GetAIStoreForValidation reads the current AIStore CR straight from the API server, bounded by `ValidationTimeout`,
+// e.g. for a validating webhook to compare the new spec against. Returns `ErrAIStoreNotFound` if there's none yet.
+func (c *K8sClient) GetAIStoreForValidation(ctx context.Context, name types.NamespacedName) (*aisv1.AIStore, error) {
+	ctx, cancel := context.WithTimeout(ctx, ValidationTimeout)
+	defer cancel()
+	aistore := &aisv1.AIStore{}
+	err := c.liveReader().Get(ctx, name, aistore)
+	return aistore, wrapNotFound(err, ErrAIStoreNotFound)
 }
 
 func (c *K8sClient) ListAIStoreCR(ctx context.Context, namespace string) (*aisv1.AIStoreList, error) {
@@ -60,10 +263,40 @@ func (c *K8sClient) ListAIStoreCR(ctx context.Context, namespace string) (*aisv1
 	return list, err
 }
 
+// ListAllAIStoreCR lists the AIStore CRs across all namespaces.
+func (c *K8sClient) ListAllAIStoreCR(ctx context.Context) (*aisv1.AIStoreList, error) {
+	return c.ListAIStoreCR(ctx, metav1.NamespaceAll)
+}
+
 func (c *K8sClient) GetStatefulSet(ctx context.Context, name types.NamespacedName) (*apiv1.StatefulSet, error) {
 	ss := &apiv1.StatefulSet{}
 	err := c.client.Get(ctx, name, ss)
-	return ss, err
+	return ss, wrapNotFound(err, ErrStatefulSetNotFound)
+}
+
+// GetStatefulSetLive is like GetStatefulSet, but always reads from the API server bypassing the informer cache.
+func (c *K8sClient) GetStatefulSetLive(ctx context.Context, name types.NamespacedName) (*apiv1.StatefulSet, error) {
+	ss := &apiv1.StatefulSet{}
+	err := c.liveReader().Get(ctx, name, ss)
+	return ss, wrapNotFound(err, ErrStatefulSetNotFound)
+}
+
+// liveReader returns the uncached reader, falling back to the (possibly cached) client if there is none.
+func (c *K8sClient) liveReader() client.Reader {
+	if c.apiReader != nil {
+		return c.apiReader
+	}
+	return c.client
+}
+
+// ListStatefulSets lists the statefulsets in the namespace matching the labels. A `NotFound` error results in an empty list.
+func (c *K8sClient) ListStatefulSets(ctx context.Context, namespace string, labels client.MatchingLabels) (*apiv1.StatefulSetList, error) {
+	list := &apiv1.StatefulSetList{}
+	err := c.client.List(ctx, list, client.InNamespace(namespace), labels)
+	if apierrors.IsNotFound(err) {
+		err = nil
+	}
+	return list, err
 }
 
 func (c *K8sClient) StatefulSetExists(ctx context.Context, name types.NamespacedName) (exists bool, err error) {
@@ -78,217 +311,1963 @@ func (c *K8sClient) StatefulSetExists(ctx context.Context, name types.Namespaced
 	return
 }
 
+// StatefulSetRolloutComplete checks if the rolling update of the statefulset finished,
+// similar to `kubectl rollout status statefulset`.
+func (c *K8sClient) StatefulSetRolloutComplete(ctx context.Context, name types.NamespacedName) (bool, error) {
+	ss, err := c.GetStatefulSet(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	replicas := statefulSetReplicas(ss)
+	return ss.Status.ObservedGeneration >= ss.Generation &&
+		ss.Status.UpdatedReplicas == replicas &&
+		ss.Status.ReadyReplicas == replicas &&
+		ss.Status.CurrentRevision == ss.Status.UpdateRevision, nil
+}
+
+func (c *K8sClient) GetDaemonSet(ctx context.Context, name types.NamespacedName) (*apiv1.DaemonSet, error) {
+	ds := &apiv1.DaemonSet{}
+	err := c.client.Get(ctx, name, ds)
+	return ds, err
+}
+
+func (c *K8sClient) DaemonSetExists(ctx context.Context, name types.NamespacedName) (exists bool, err error) {
+	_, err = c.GetDaemonSet(ctx, name)
+	if err == nil {
+		exists = true
+		return
+	}
+	if apierrors.IsNotFound(err) {
+		err = nil
+	}
+	return
+}
+
 func (c *K8sClient) GetServiceByName(ctx context.Context, name types.NamespacedName) (*corev1.Service, error) {
 	svc := &corev1.Service{}
 	err := c.client.Get(ctx, name, svc)
-	return svc, err
+	return svc, wrapNotFound(err, ErrServiceNotFound)
 }
 
-func (c *K8sClient) GetCMByName(ctx context.Context, name types.NamespacedName) (*corev1.ConfigMap, error) {
-	cm := &corev1.ConfigMap{}
-	err := c.client.Get(ctx, name, cm)
-	return cm, err
+// GetServiceNodePorts returns the allocated node port of each port of the service, keyed by port name.
+func (c *K8sClient) GetServiceNodePorts(ctx context.Context, name types.NamespacedName) (map[string]int32, error) {
+	svc, err := c.GetServiceByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	nodePorts := make(map[string]int32, len(svc.Spec.Ports))
+	for _, port := range svc.Spec.Ports {
+		if port.NodePort != 0 {
+			nodePorts[port.Name] = port.NodePort
+		}
+	}
+	return nodePorts, nil
 }
 
-func (c *K8sClient) GetPodByName(ctx context.Context, name types.NamespacedName) (*corev1.Pod, error) {
-	pod := &corev1.Pod{}
-	err := c.client.Get(ctx, name, pod)
-	return pod, err
+func (c *K8sClient) GetIngressByName(ctx context.Context, name types.NamespacedName) (*networkingv1.Ingress, error) {
+	ing := &networkingv1.Ingress{}
+	err := c.client.Get(ctx, name, ing)
+	return ing, err
 }
 
-func (c *K8sClient) GetRoleByName(ctx context.Context, name types.NamespacedName) (*rbacv1.Role, error) {
-	role := &rbacv1.Role{}
-	err := c.client.Get(ctx, name, role)
-	return role, err
+func (c *K8sClient) GetCMByName(ctx context.Context, name types.NamespacedName) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{}
+	err := c.client.Get(ctx, name, cm)
+	return cm, err
 }
 
-func (c *K8sClient) Status() client.StatusWriter { return c.client.Status() }
-
-///////////////////////////////////////
-//      create/update resources     //
-//////////////////////////////////////
-
-func (c *K8sClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
-	return c.client.Update(ctx, obj, opts...)
+func (c *K8sClient) GetSecretByName(ctx context.Context, name types.NamespacedName) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	err := c.client.Get(ctx, name, secret)
+	return secret, err
 }
 
-func (c *K8sClient) UpdateIfExists(ctx context.Context, res client.Object) error {
-	err := c.client.Update(ctx, res)
-	if apierrors.IsNotFound(err) {
-		return nil
+// GetSecretResourceVersion returns the `resourceVersion` of the Secret, which changes whenever the Secret is updated,
+// e.g. when cert-manager rotates the certificates.
+func (c *K8sClient) GetSecretResourceVersion(ctx context.Context, name types.NamespacedName) (string, error) {
+	secret, err := c.GetSecretByName(ctx, name)
+	if err != nil {
+		return "", err
 	}
-	return err
+	return secret.ResourceVersion, nil
 }
 
-func (c *K8sClient) UpdateStatefulSetReplicas(ctx context.Context, name types.NamespacedName, size int32) (updated bool, err error) {
-	ss, err := c.GetStatefulSet(ctx, name)
+// CheckStorageClassExists checks if the StorageClass exists, additionally reporting whether it allows
+// volume expansion (required by `ResizePVC`).
+func (c *K8sClient) CheckStorageClassExists(ctx context.Context, name string) (exists, allowsExpansion bool, err error) {
+	sc := &storagev1.StorageClass{}
+	err = c.client.Get(ctx, types.NamespacedName{Name: name}, sc)
 	if err != nil {
+		if apierrors.IsNotFound(err) {
+			err = nil
+		}
 		return
 	}
-	updated = *ss.Spec.Replicas != size
-	if !updated {
-		return
-	}
-	ss.Spec.Replicas = &size
-	err = c.client.Update(ctx, ss)
-	return
+	return true, sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion, nil
 }
 
-func (c *K8sClient) UpdateStatefulSetImage(ctx context.Context, name types.NamespacedName, idx int, newImage string) (updated bool, err error) {
-	ss, err := c.GetStatefulSet(ctx, name)
-	if err != nil {
+func (c *K8sClient) GetPVCByName(ctx context.Context, name types.NamespacedName) (*corev1.PersistentVolumeClaim, error) {
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := c.client.Get(ctx, name, pvc)
+	return pvc, err
+}
+
+func (c *K8sClient) PVCExists(ctx context.Context, name types.NamespacedName) (exists bool, err error) {
+	_, err = c.GetPVCByName(ctx, name)
+	if err == nil {
+		exists = true
 		return
 	}
-	updated = ss.Spec.Template.Spec.Containers[idx].Image != newImage
-	if !updated {
-		return
+	if apierrors.IsNotFound(err) {
+		err = nil
 	}
-	ss.Spec.Template.Spec.Containers[idx].Image = newImage
-	err = c.client.Update(ctx, ss)
 	return
 }
 
-func (c *K8sClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
-	return c.client.Create(ctx, obj, opts...)
+func (c *K8sClient) GetPodByName(ctx context.Context, name types.NamespacedName) (*corev1.Pod, error) {
+	pod := &corev1.Pod{}
+	err := c.client.Get(ctx, name, pod)
+	return pod, err
 }
 
-func (c *K8sClient) CreateResourceIfNotExists(ctx context.Context, owner *aisv1.AIStore, res client.Object) (exists bool, err error) {
-	if owner != nil {
-		if err = controllerutil.SetControllerReference(owner, res, c.scheme); err != nil {
-			return
-		}
-		res.SetNamespace(owner.Namespace)
-	}
-
-	err = c.client.Create(ctx, res)
-	exists = err != nil && apierrors.IsAlreadyExists(err)
-	if exists {
+// ListPods lists the pods in the namespace matching the labels. A `NotFound` error results in an empty list.
+func (c *K8sClient) ListPods(ctx context.Context, namespace string, labels client.MatchingLabels) (*corev1.PodList, error) {
+	pods := &corev1.PodList{}
+	err := c.client.List(ctx, pods, client.InNamespace(namespace), labels)
+	if apierrors.IsNotFound(err) {
 		err = nil
 	}
-	return
+	return pods, err
 }
 
-func (c *K8sClient) CheckIfNamespaceExists(ctx context.Context, name string) (exists bool, err error) {
-	ns := &corev1.Namespace{}
-	err = c.client.Get(ctx, types.NamespacedName{Name: name}, ns)
-	if err == nil {
-		exists = true
-	} else if apierrors.IsNotFound(err) {
+// ListJobs lists the jobs in the namespace matching the labels. A `NotFound` error results in an empty list.
+func (c *K8sClient) ListJobs(ctx context.Context, namespace string, labels client.MatchingLabels) (*batchv1.JobList, error) {
+	jobs := &batchv1.JobList{}
+	err := c.client.List(ctx, jobs, client.InNamespace(namespace), labels)
+	if apierrors.IsNotFound(err) {
 		err = nil
 	}
-	return exists, err
+	return jobs, err
 }
 
-/////////////////////////////////
-//       Delete resources      //
-////////////////////////////////
+func (c *K8sClient) GetNode(ctx context.Context, name string) (*corev1.Node, error) {
+	node := &corev1.Node{}
+	err := c.client.Get(ctx, types.NamespacedName{Name: name}, node)
+	return node, err
+}
+
+// ListNodes lists the nodes matching the labels, e.g. to check the zones (`topology.kubernetes.io/zone`)
+// available for spreading targets.
+func (c *K8sClient) ListNodes(ctx context.Context, labels client.MatchingLabels) (*corev1.NodeList, error) {
+	nodes := &corev1.NodeList{}
+	err := c.client.List(ctx, nodes, labels)
+	return nodes, err
+}
 
-// DeleteResourceIfExists deletes an existing resource. It doesn't fail if the resource does not exist
-func (c *K8sClient) DeleteResourceIfExists(ctx context.Context, obj client.Object) (existed bool, err error) {
-	err = c.client.Delete(ctx, obj)
+// CheckSchedulability checks that there are at least `replicas` schedulable nodes matching the labels (e.g. the
+// targets' node selector), as required to place one pod per node with anti-affinity (see `target.BuildTargetAntiAffinity`).
+// Nodes that are cordoned, not ready or have `NoSchedule`/`NoExecute` taints aren't counted; tolerations aren't considered.
+func (c *K8sClient) CheckSchedulability(ctx context.Context, replicas int32, labels client.MatchingLabels) error {
+	nodes, err := c.ListNodes(ctx, labels)
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			return false, nil
+		return err
+	}
+	var schedulable int32
+	for i := range nodes.Items {
+		if isNodeSchedulable(&nodes.Items[i]) {
+			schedulable++
 		}
-		err = fmt.Errorf("failed to delete %s: %q (namespace %q); err %v", obj.GetObjectKind(), obj.GetName(), obj.GetNamespace(), err)
-		return false, err
 	}
-	return true, nil
+	if schedulable < replicas {
+		return fmt.Errorf("%w: %d nodes for %d replicas", ErrNotEnoughNodes, schedulable, replicas)
+	}
+	return nil
 }
 
-func (c *K8sClient) DeleteServiceIfExists(ctx context.Context, name types.NamespacedName) (existed bool, err error) {
-	svc := &corev1.Service{}
-	svc.SetName(name.Name)
-	svc.SetNamespace(name.Namespace)
-	return c.DeleteResourceIfExists(ctx, svc)
+func isNodeSchedulable(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute {
+			return false
+		}
+	}
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
 }
 
-func (c *K8sClient) DeleteAllServicesIfExist(ctx context.Context, namespace string, labels client.MatchingLabels) (anyExisted bool, err error) {
-	svcs := &corev1.ServiceList{}
-	err = c.client.List(ctx, svcs, client.InNamespace(namespace), labels)
+// CordonNode marks the node (un)schedulable, e.g. ahead of host maintenance, so that no new pods
+// (including the AIS target once evicted) are scheduled on it.
+func (c *K8sClient) CordonNode(ctx context.Context, name string, cordon bool) (updated bool, err error) {
+	node, err := c.GetNode(ctx, name)
+	if err != nil || node.Spec.Unschedulable == cordon {
+		return
+	}
+	patch := client.MergeFrom(node.DeepCopy())
+	node.Spec.Unschedulable = cordon
+	if err = c.client.Patch(ctx, node, patch); err != nil {
+		return
+	}
+	return true, nil
+}
+
+// EvictPodsOnNode evicts the pods in the namespace matching the labels which run on the node, through the eviction
+// subresource, so that PodDisruptionBudgets are respected. It should be called only after the AIS targets
+// on the node were decommissioned, so that no data is lost. Returns the number of evicted pods.
+func (c *K8sClient) EvictPodsOnNode(ctx context.Context, nodeName, namespace string, labels client.MatchingLabels) (evicted int, err error) {
+	pods, err := c.ListPods(ctx, namespace, labels)
 	if err != nil {
+		return
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName != nodeName {
+			continue
+		}
+		eviction := &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace}}
+		err = c.clientset.PolicyV1().Evictions(pod.Namespace).Evict(ctx, eviction)
 		if apierrors.IsNotFound(err) {
 			err = nil
+			continue
 		}
-		return
-	}
-
-	for i := range svcs.Items {
-		var existed bool
-		existed, err = c.DeleteResourceIfExists(ctx, &svcs.Items[i])
 		if err != nil {
-			return
+			return evicted, fmt.Errorf("failed to evict pod %q: %w", pod.Name, err)
 		}
-		anyExisted = anyExisted || existed
+		evicted++
 	}
 	return
 }
 
-func (c *K8sClient) DeleteAllPVCsIfExist(ctx context.Context, namespace string, labels client.MatchingLabels) (anyExisted bool, err error) {
-	pvcs := &corev1.PersistentVolumeClaimList{}
-	err = c.client.List(ctx, pvcs, client.InNamespace(namespace), labels)
+// ListOrphanedPods lists the pods in the namespace matching the labels which aren't controlled by
+// any of the statefulsets in the namespace, e.g. after the ownership was removed by a manual edit.
+func (c *K8sClient) ListOrphanedPods(ctx context.Context, namespace string, labels client.MatchingLabels) ([]corev1.Pod, error) {
+	pods, err := c.ListPods(ctx, namespace, labels)
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			err = nil
-		}
-		return
+		return nil, err
+	}
+	ssList, err := c.ListStatefulSets(ctx, namespace, nil)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[types.UID]struct{}, len(ssList.Items))
+	for i := range ssList.Items {
+		known[ssList.Items[i].UID] = struct{}{}
 	}
 
-	for i := range pvcs.Items {
-		var existed bool
-		existed, err = c.DeleteResourceIfExists(ctx, &pvcs.Items[i])
-		if err != nil {
-			return
+	var orphaned []corev1.Pod
+	for i := range pods.Items {
+		ref := metav1.GetControllerOf(&pods.Items[i])
+		if ref != nil && ref.Kind == "StatefulSet" {
+			if _, ok := known[ref.UID]; ok {
+				continue
+			}
 		}
-		anyExisted = anyExisted || existed
+		orphaned = append(orphaned, pods.Items[i])
 	}
-	return
+	return orphaned, nil
 }
 
-func (c *K8sClient) DeleteStatefulSetIfExists(ctx context.Context, name types.NamespacedName) (existed bool, err error) {
-	ss := &apiv1.StatefulSet{}
-	ss.SetName(name.Name)
-	ss.SetNamespace(name.Namespace)
-	return c.DeleteResourceIfExists(ctx, ss)
-}
+// GetStatefulSetPods lists the pods controlled by the statefulset, sorted by their ordinal index.
+func (c *K8sClient) GetStatefulSetPods(ctx context.Context, name types.NamespacedName) ([]corev1.Pod, error) {
+	ss, err := c.GetStatefulSet(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(ss.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	list := &corev1.PodList{}
+	if err = c.client.List(ctx, list, client.InNamespace(name.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
 
-func (c *K8sClient) DeleteConfigMapIfExists(ctx context.Context, name types.NamespacedName) (existed bool, err error) {
-	ss := &corev1.ConfigMap{}
-	ss.SetName(name.Name)
-	ss.SetNamespace(name.Namespace)
-	return c.DeleteResourceIfExists(ctx, ss)
+	pods := make([]corev1.Pod, 0, len(list.Items))
+	ordinals := make(map[string]int, len(list.Items))
+	for i := range list.Items {
+		pod := list.Items[i]
+		if !metav1.IsControlledBy(&pod, ss) {
+			continue
+		}
+		ordinal, err := podOrdinal(pod.Name, ss.Name)
+		if err != nil {
+			return nil, err
+		}
+		ordinals[pod.Name] = ordinal
+		pods = append(pods, pod)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return ordinals[pods[i].Name] < ordinals[pods[j].Name]
+	})
+	return pods, nil
 }
 
-func (c *K8sClient) DeletePodIfExists(ctx context.Context, name types.NamespacedName) (err error) {
-	pod := &corev1.Pod{}
-	pod.SetName(name.Name)
-	pod.SetNamespace(name.Namespace)
-	_, err = c.DeleteResourceIfExists(ctx, pod)
-	return
-}
+// GetPVCsForPod returns the PVCs of the statefulset pod created from each of the statefulset's volume claim
+// templates (e.g. one per target mountpath), i.e. `<template>-<pod>`. The statefulset is derived from the pod
+// name, so this works even if the pod itself no longer exists. Missing PVCs are skipped.
+func (c *K8sClient) GetPVCsForPod(ctx context.Context, podName types.NamespacedName) ([]corev1.PersistentVolumeClaim, error) {
+	idx := strings.LastIndex(podName.Name, "-")
+	if idx <= 0 {
+		return nil, fmt.Errorf("pod %q is not a statefulset pod", podName)
+	}
+	ssName := types.NamespacedName{Name: podName.Name[:idx], Namespace: podName.Namespace}
+	if _, err := podOrdinal(podName.Name, ssName.Name); err != nil {
+		return nil, err
+	}
+	ss, err := c.GetStatefulSet(ctx, ssName)
+	if err != nil {
+		return nil, err
+	}
 
-func (c *K8sClient) WaitForPodReady(ctx context.Context, name types.NamespacedName, timeout time.Duration) error {
-	var (
-		retryInterval   = 3 * time.Second
-		ctxBack, cancel = context.WithTimeout(ctx, timeout)
-		pod             *corev1.Pod
-		err             error
-	)
-	defer cancel()
-	for {
-		pod, err = c.GetPodByName(ctx, name)
-		if err != nil {
+	pvcs := make([]corev1.PersistentVolumeClaim, 0, len(ss.Spec.VolumeClaimTemplates))
+	for i := range ss.Spec.VolumeClaimTemplates {
+		pvcName := types.NamespacedName{
+			Name:      ss.Spec.VolumeClaimTemplates[i].Name + "-" + podName.Name,
+			Namespace: podName.Namespace,
+		}
+		pvc, err := c.GetPVCByName(ctx, pvcName)
+		if apierrors.IsNotFound(err) {
 			continue
 		}
-		if pod.Status.Phase == corev1.PodRunning {
-			return nil
+		if err != nil {
+			return nil, err
 		}
-		time.Sleep(retryInterval)
-		select {
-		case <-ctxBack.Done():
-			return ctxBack.Err()
-		default:
-			break
+		pvcs = append(pvcs, *pvc)
+	}
+	return pvcs, nil
+}
+
+// MatchPVCsToOrdinals verifies that the PVC of each ordinal and volume claim template of the statefulset is bound to
+// a PV claiming exactly that PVC, e.g. after the AIStore CR was deleted and recreated with retained PVs, so that no pod
+// gets the data volume of another ordinal. PVCs that are missing or not bound yet are skipped.
+func (c *K8sClient) MatchPVCsToOrdinals(ctx context.Context, name types.NamespacedName) ([]PVCMismatch, error) {
+	ss, err := c.GetStatefulSet(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var mismatches []PVCMismatch
+	for ordinal := int32(0); ordinal < statefulSetReplicas(ss); ordinal++ {
+		for i := range ss.Spec.VolumeClaimTemplates {
+			pvcName := fmt.Sprintf("%s-%s-%d", ss.Spec.VolumeClaimTemplates[i].Name, name.Name, ordinal)
+			pvc, err := c.GetPVCByName(ctx, types.NamespacedName{Name: pvcName, Namespace: name.Namespace})
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if pvc.Spec.VolumeName == "" {
+				continue
+			}
+			pv := &corev1.PersistentVolume{}
+			if err := c.client.Get(ctx, types.NamespacedName{Name: pvc.Spec.VolumeName}, pv); err != nil {
+				return nil, err
+			}
+			ref := pv.Spec.ClaimRef
+			if ref != nil && ref.Namespace == pvc.Namespace && ref.Name == pvc.Name && (ref.UID == "" || ref.UID == pvc.UID) {
+				continue
+			}
+			mismatch := PVCMismatch{PVC: pvc.Name, PV: pv.Name}
+			if ref != nil {
+				mismatch.ClaimedBy = ref.Namespace + "/" + ref.Name
+			}
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	return mismatches, nil
+}
+
+// CheckPodPVCLocality checks that the pod runs on a node satisfying the node affinity of the PVs bound to its PVCs,
+// as set for local volumes that can only be accessed from a single node. Returns false if the pod has been scheduled
+// elsewhere, e.g. after a reschedule. Unbound PVCs, PVs without node affinity and unscheduled pods are not a mismatch.
+func (c *K8sClient) CheckPodPVCLocality(ctx context.Context, podName types.NamespacedName) (bool, error) {
+	pod, err := c.GetPodByName(ctx, podName)
+	if err != nil {
+		return false, err
+	}
+	if pod.Spec.NodeName == "" {
+		return true, nil
+	}
+	node, err := c.GetNode(ctx, pod.Spec.NodeName)
+	if err != nil {
+		return false, err
+	}
+	for i := range pod.Spec.Volumes {
+		claim := pod.Spec.Volumes[i].PersistentVolumeClaim
+		if claim == nil {
+			continue
+		}
+		pvc, err := c.GetPVCByName(ctx, types.NamespacedName{Name: claim.ClaimName, Namespace: podName.Namespace})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if pvc.Spec.VolumeName == "" {
+			continue
+		}
+		pv := &corev1.PersistentVolume{}
+		if err := c.client.Get(ctx, types.NamespacedName{Name: pvc.Spec.VolumeName}, pv); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+		if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+			continue
+		}
+		if !nodeMatchesSelector(node, pv.Spec.NodeAffinity.Required) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// nodeMatchesSelector evaluates the node selector terms (ORed) against the node labels and name,
+// the same way the scheduler does for required node affinity.
+func nodeMatchesSelector(node *corev1.Node, selector *corev1.NodeSelector) bool {
+	for _, term := range selector.NodeSelectorTerms {
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			continue
+		}
+		if matchesNodeRequirements(node.Labels, term.MatchExpressions) &&
+			matchesNodeRequirements(k8slabels.Set{"metadata.name": node.Name}, term.MatchFields) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesNodeRequirements(set k8slabels.Set, reqs []corev1.NodeSelectorRequirement) bool {
+	for _, req := range reqs {
+		var op selection.Operator
+		switch req.Operator {
+		case corev1.NodeSelectorOpIn:
+			op = selection.In
+		case corev1.NodeSelectorOpNotIn:
+			op = selection.NotIn
+		case corev1.NodeSelectorOpExists:
+			op = selection.Exists
+		case corev1.NodeSelectorOpDoesNotExist:
+			op = selection.DoesNotExist
+		case corev1.NodeSelectorOpGt:
+			op = selection.GreaterThan
+		case corev1.NodeSelectorOpLt:
+			op = selection.LessThan
+		default:
+			return false
+		}
+		r, err := k8slabels.NewRequirement(req.Key, op, req.Values)
+		if err != nil || !r.Matches(set) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetPodRestartCount returns the restart count of the pod container.
+func (c *K8sClient) GetPodRestartCount(ctx context.Context, name types.NamespacedName, container string) (int32, error) {
+	pod, err := c.GetPodByName(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == container {
+			return pod.Status.ContainerStatuses[i].RestartCount, nil
+		}
+	}
+	return 0, fmt.Errorf("container %q not found in pod %q", container, name)
+}
+
+// GetHighRestartPods lists the pods in the namespace matching the labels with any container restarted
+// at least `threshold` times, e.g. crash-looping targets.
+func (c *K8sClient) GetHighRestartPods(ctx context.Context, namespace string, labels client.MatchingLabels,
+	threshold int32) ([]corev1.Pod, error) {
+	pods, err := c.ListPods(ctx, namespace, labels)
+	if err != nil {
+		return nil, err
+	}
+	var highRestart []corev1.Pod
+	for i := range pods.Items {
+		for _, status := range pods.Items[i].Status.ContainerStatuses {
+			if status.RestartCount >= threshold {
+				highRestart = append(highRestart, pods.Items[i])
+				break
+			}
+		}
+	}
+	return highRestart, nil
+}
+
+// GetPodLogs returns the last `tailLines` lines of logs of the pod container.
+func (c *K8sClient) GetPodLogs(ctx context.Context, name types.NamespacedName, container string, tailLines int64) (string, error) {
+	opts := &corev1.PodLogOptions{
+		Container: container,
+		TailLines: &tailLines,
+	}
+	logs, err := c.clientset.CoreV1().Pods(name.Namespace).GetLogs(name.Name, opts).DoRaw(ctx)
+	if err != nil {
+		return "", err
+	}
+	return string(logs), nil
+}
+
+// ExecInPod runs the command in the pod container (e.g. `ais` CLI) and returns its output.
+// NOTE: the context is only checked before starting the command; client-go doesn't support cancelling the stream.
+func (c *K8sClient) ExecInPod(ctx context.Context, name types.NamespacedName, container string,
+	cmd []string) (stdout, stderr string, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	req := c.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(name.Namespace).
+		Name(name.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   cmd,
+			Stdout:    true,
+			Stderr:    true,
+		}, clientgoscheme.ParameterCodec)
+	exec, err := remotecommand.NewSPDYExecutor(c.config, "POST", req.URL())
+	if err != nil {
+		return
+	}
+	var outBuf, errBuf bytes.Buffer
+	err = exec.Stream(remotecommand.StreamOptions{
+		Stdout: &outBuf,
+		Stderr: &errBuf,
+	})
+	stdout, stderr = outBuf.String(), errBuf.String()
+	if err != nil {
+		err = fmt.Errorf("failed to exec %v in pod %q (container %q): %v, stderr: %q", cmd, name, container, err, stderr)
+	}
+	return
+}
+
+func (c *K8sClient) GetRoleByName(ctx context.Context, name types.NamespacedName) (*rbacv1.Role, error) {
+	role := &rbacv1.Role{}
+	err := c.client.Get(ctx, name, role)
+	return role, err
+}
+
+func (c *K8sClient) Status() client.StatusWriter { return c.client.Status() }
+
+///////////////////////////////////////
+//      create/update resources     //
+//////////////////////////////////////
+
+func (c *K8sClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return c.client.Update(ctx, obj, opts...)
+}
+
+func (c *K8sClient) UpdateIfExists(ctx context.Context, res client.Object, opts ...client.UpdateOption) error {
+	err := c.client.Update(ctx, res, opts...)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// UpdateConfigMapIfChanged updates the data of an existing ConfigMap only if it differs from `cm`,
+// keeping the `resourceVersion` stable (and avoiding spurious restarts of its watchers) otherwise.
+func (c *K8sClient) UpdateConfigMapIfChanged(ctx context.Context, cm *corev1.ConfigMap, opts ...client.UpdateOption) (updated bool, err error) {
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := c.GetCMByName(ctx, types.NamespacedName{Name: cm.Name, Namespace: cm.Namespace})
+		if err != nil {
+			return err
+		}
+		updated = !equality.Semantic.DeepEqual(existing.Data, cm.Data) ||
+			!equality.Semantic.DeepEqual(existing.BinaryData, cm.BinaryData)
+		if !updated {
+			return nil
+		}
+		existing.Data = cm.Data
+		existing.BinaryData = cm.BinaryData
+		return c.client.Update(ctx, existing, opts...)
+	})
+	return
+}
+
+// UpdateServicePorts patches the ports of an existing Service, e.g. when the AIS ports change in the CR.
+// Node ports already allocated to ports with the same name are preserved, as is the cluster IP.
+// Returns updated=false if the ports already match.
+func (c *K8sClient) UpdateServicePorts(ctx context.Context, name types.NamespacedName, ports []corev1.ServicePort) (updated bool, err error) {
+	svc, err := c.GetServiceByName(ctx, name)
+	if err != nil {
+		return
+	}
+	allocated := make(map[string]int32, len(svc.Spec.Ports))
+	for _, port := range svc.Spec.Ports {
+		allocated[port.Name] = port.NodePort
+	}
+	desired := make([]corev1.ServicePort, len(ports))
+	for i, port := range ports {
+		// Fill in the defaults set by the API server, to compare against the existing ports.
+		if port.Protocol == "" {
+			port.Protocol = corev1.ProtocolTCP
+		}
+		if port.TargetPort.Type == intstr.Int && port.TargetPort.IntVal == 0 {
+			port.TargetPort = intstr.FromInt(int(port.Port))
+		}
+		if port.NodePort == 0 && svc.Spec.Type != corev1.ServiceTypeClusterIP {
+			port.NodePort = allocated[port.Name]
+		}
+		desired[i] = port
+	}
+	if equality.Semantic.DeepEqual(svc.Spec.Ports, desired) {
+		return
+	}
+
+	patch := client.MergeFrom(svc.DeepCopy())
+	svc.Spec.Ports = desired
+	if err = c.client.Patch(ctx, svc, patch); err != nil {
+		return
+	}
+	return true, nil
+}
+
+// ReconcileServiceType changes the type of an existing Service between ClusterIP, NodePort and LoadBalancer,
+// e.g. to expose the proxies outside of the K8s cluster. Fields not valid for the new type are cleared,
+// i.e. node ports when switching to ClusterIP and the load balancer settings when switching away from LoadBalancer;
+// the cluster IP is preserved. Headless services can't be exposed, as their cluster IP (`None`) is immutable.
+func (c *K8sClient) ReconcileServiceType(ctx context.Context, name types.NamespacedName,
+	newType corev1.ServiceType) (updated bool, err error) {
+	switch newType {
+	case corev1.ServiceTypeClusterIP, corev1.ServiceTypeNodePort, corev1.ServiceTypeLoadBalancer:
+	default:
+		return false, fmt.Errorf("unsupported type %q for service %q", newType, name)
+	}
+	svc, err := c.GetServiceByName(ctx, name)
+	if err != nil || svc.Spec.Type == newType {
+		return
+	}
+	if newType != corev1.ServiceTypeClusterIP && svc.Spec.ClusterIP == corev1.ClusterIPNone {
+		return false, fmt.Errorf("headless service %q can't be changed to %q", name, newType)
+	}
+
+	patch := client.MergeFrom(svc.DeepCopy())
+	svc.Spec.Type = newType
+	if newType == corev1.ServiceTypeClusterIP {
+		for i := range svc.Spec.Ports {
+			svc.Spec.Ports[i].NodePort = 0
+		}
+		svc.Spec.ExternalTrafficPolicy = ""
+	}
+	if newType != corev1.ServiceTypeLoadBalancer {
+		svc.Spec.LoadBalancerIP = ""
+		svc.Spec.LoadBalancerSourceRanges = nil
+		svc.Spec.LoadBalancerClass = nil
+		svc.Spec.AllocateLoadBalancerNodePorts = nil
+		svc.Spec.HealthCheckNodePort = 0
+	}
+	if err = c.client.Patch(ctx, svc, patch); err != nil {
+		return
+	}
+	return true, nil
+}
+
+// ResizePVC expands the storage request of the PVC to `newSize`.
+// It fails if `newSize` isn't larger than the current request, or if the PVC's StorageClass doesn't allow volume expansion.
+func (c *K8sClient) ResizePVC(ctx context.Context, name types.NamespacedName, newSize resource.Quantity) (updated bool, err error) {
+	pvc, err := c.GetPVCByName(ctx, name)
+	if err != nil {
+		return
+	}
+	current := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	switch newSize.Cmp(current) {
+	case 0:
+		return
+	case -1:
+		err = fmt.Errorf("cannot shrink PVC %q from %s to %s", name, current.String(), newSize.String())
+		return
+	}
+
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		err = fmt.Errorf("cannot resize PVC %q without a StorageClass", name)
+		return
+	}
+	sc := &storagev1.StorageClass{}
+	if err = c.client.Get(ctx, types.NamespacedName{Name: *pvc.Spec.StorageClassName}, sc); err != nil {
+		return
+	}
+	if sc.AllowVolumeExpansion == nil || !*sc.AllowVolumeExpansion {
+		err = fmt.Errorf("cannot resize PVC %q, StorageClass %q doesn't allow volume expansion", name, sc.Name)
+		return
+	}
+
+	patch := client.MergeFrom(pvc.DeepCopy())
+	if pvc.Spec.Resources.Requests == nil {
+		pvc.Spec.Resources.Requests = corev1.ResourceList{}
+	}
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = newSize
+	if err = c.client.Patch(ctx, pvc, patch); err != nil {
+		return
+	}
+	return true, nil
+}
+
+// UpdateResourceWithRetry fetches the latest state of the resource into `res` (by its name), applies `mutate`
+// and updates it, retrying on conflicts. `mutate` returns false if the resource already has the desired state,
+// in which case no update is issued.
+func (c *K8sClient) UpdateResourceWithRetry(ctx context.Context, res client.Object, mutate func() bool,
+	opts ...client.UpdateOption) (updated bool, err error) {
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := c.client.Get(ctx, client.ObjectKeyFromObject(res), res); err != nil {
+			return err
+		}
+		if updated = mutate(); !updated {
+			return nil
+		}
+		return c.client.Update(ctx, res, opts...)
+	})
+	return
+}
+
+// UpdateWithRetry fetches the latest statefulset, applies `mutate` and updates it, retrying on conflicts.
+// `mutate` returns false if the statefulset already has the desired state, in which case no update is issued.
+// With `client.DryRunAll` the update isn't persisted, and the statefulset passed to `mutate` is filled
+// with the would-be result returned by the API server.
+func (c *K8sClient) UpdateWithRetry(ctx context.Context, name types.NamespacedName,
+	mutate func(*apiv1.StatefulSet) bool, opts ...client.UpdateOption) (updated bool, err error) {
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ss, err := c.GetStatefulSet(ctx, name)
+		if err != nil {
+			return err
+		}
+		if updated = mutate(ss); !updated {
+			return nil
+		}
+		return c.client.Update(ctx, ss, opts...)
+	})
+	return
+}
+
+func (c *K8sClient) UpdateStatefulSetReplicas(ctx context.Context, name types.NamespacedName, size int32,
+	opts ...client.UpdateOption) (updated bool, err error) {
+	_, updated, err = c.UpdateStatefulSetReplicasDetailed(ctx, name, size, opts...)
+	return
+}
+
+// UpdateStatefulSetReplicasDetailed updates the replicas, additionally returning the replica count before the update,
+// e.g. to tell scale-up from scale-down.
+func (c *K8sClient) UpdateStatefulSetReplicasDetailed(ctx context.Context, name types.NamespacedName,
+	size int32, opts ...client.UpdateOption) (previous int32, updated bool, err error) {
+	updated, err = c.UpdateWithRetry(ctx, name, func(ss *apiv1.StatefulSet) bool {
+		previous = statefulSetReplicas(ss)
+		if previous == size {
+			return false
+		}
+		ss.Spec.Replicas = &size
+		return true
+	}, opts...)
+	return
+}
+
+func (c *K8sClient) UpdateStatefulSetImage(ctx context.Context, name types.NamespacedName, idx int, newImage string,
+	opts ...client.UpdateOption) (updated bool, err error) {
+	var idxErr error
+	updated, err = c.UpdateWithRetry(ctx, name, func(ss *apiv1.StatefulSet) bool {
+		if idxErr = checkContainerIdx(ss, idx); idxErr != nil {
+			return false
+		}
+		containers := ss.Spec.Template.Spec.Containers
+		if containers[idx].Image == newImage {
+			return false
+		}
+		containers[idx].Image = newImage
+		return true
+	}, opts...)
+	if err == nil {
+		err = idxErr
+	}
+	return
+}
+
+// UpdateStatefulSetResources patches the resource requirements of the container at `idx`,
+// leaving the rest of the statefulset untouched. Returns updated=false if the requirements already match.
+func (c *K8sClient) UpdateStatefulSetResources(ctx context.Context, name types.NamespacedName, idx int,
+	resources corev1.ResourceRequirements, opts ...client.PatchOption) (updated bool, err error) {
+	ss, err := c.GetStatefulSet(ctx, name)
+	if err != nil {
+		return
+	}
+	if err = checkContainerIdx(ss, idx); err != nil {
+		return
+	}
+	updated = !equality.Semantic.DeepEqual(ss.Spec.Template.Spec.Containers[idx].Resources, resources)
+	if !updated {
+		return
+	}
+	patch := client.StrategicMergeFrom(ss.DeepCopy())
+	ss.Spec.Template.Spec.Containers[idx].Resources = resources
+	err = c.client.Patch(ctx, ss, patch, opts...)
+	return
+}
+
+// UpdateStatefulSetImageByName updates the image of the container with the given name.
+// Unlike indexes, container names remain stable when sidecars are added to the pod template.
+func (c *K8sClient) UpdateStatefulSetImageByName(ctx context.Context, name types.NamespacedName, container, newImage string,
+	opts ...client.UpdateOption) (updated bool, err error) {
+	var found bool
+	updated, err = c.UpdateWithRetry(ctx, name, func(ss *apiv1.StatefulSet) bool {
+		for idx := range ss.Spec.Template.Spec.Containers {
+			if ss.Spec.Template.Spec.Containers[idx].Name != container {
+				continue
+			}
+			found = true
+			if ss.Spec.Template.Spec.Containers[idx].Image == newImage {
+				return false
+			}
+			ss.Spec.Template.Spec.Containers[idx].Image = newImage
+			return true
+		}
+		found = false
+		return false
+	}, opts...)
+	if err == nil && !found {
+		err = fmt.Errorf("container %q not found in statefulset %q", container, name)
+	}
+	return
+}
+
+// RestartStatefulSetOnSecretChange triggers a rolling restart of the statefulset pods if the Secret (e.g. the TLS
+// certificates) changed since it was stamped on the statefulset with AnnotationTLSSecretVersion, so that the pods
+// pick up the rotated certificates. The first call on a statefulset without the stamp (e.g. created by an older
+// operator) only records the current version, without restarting the pods.
+func (c *K8sClient) RestartStatefulSetOnSecretChange(ctx context.Context, name, secretName types.NamespacedName) (restarted bool, err error) {
+	version, err := c.GetSecretResourceVersion(ctx, secretName)
+	if err != nil {
+		return
+	}
+	_, err = c.UpdateWithRetry(ctx, name, func(ss *apiv1.StatefulSet) bool {
+		recorded, ok := ss.Annotations[AnnotationTLSSecretVersion]
+		if !ok {
+			recorded, ok = ss.Spec.Template.Annotations[AnnotationTLSSecretVersion]
+		}
+		if ok && recorded == version {
+			restarted = false
+			return false
+		}
+		metav1.SetMetaDataAnnotation(&ss.ObjectMeta, AnnotationTLSSecretVersion, version)
+		// Changing the pod template rolls out the pods.
+		restarted = ok
+		if restarted {
+			metav1.SetMetaDataAnnotation(&ss.Spec.Template.ObjectMeta, AnnotationTLSSecretVersion, version)
+		}
+		return true
+	})
+	if err != nil {
+		restarted = false
+	}
+	return
+}
+
+// EnsureStatefulSetInitContainer adds the init container to the statefulset pod template (after the existing ones),
+// or updates the image, command, args and volume mounts of the init container with the same name.
+func (c *K8sClient) EnsureStatefulSetInitContainer(ctx context.Context, name types.NamespacedName, container corev1.Container,
+	opts ...client.UpdateOption) (updated bool, err error) {
+	return c.UpdateWithRetry(ctx, name, func(ss *apiv1.StatefulSet) bool {
+		initContainers := ss.Spec.Template.Spec.InitContainers
+		for idx := range initContainers {
+			current := &initContainers[idx]
+			if current.Name != container.Name {
+				continue
+			}
+			if current.Image == container.Image && equality.Semantic.DeepEqual(current.Command, container.Command) &&
+				equality.Semantic.DeepEqual(current.Args, container.Args) &&
+				equality.Semantic.DeepEqual(current.VolumeMounts, container.VolumeMounts) {
+				return false
+			}
+			current.Image = container.Image
+			current.Command = container.Command
+			current.Args = container.Args
+			current.VolumeMounts = container.VolumeMounts
+			return true
+		}
+		ss.Spec.Template.Spec.InitContainers = append(initContainers, container)
+		return true
+	}, opts...)
+}
+
+// UpdateStatefulSetNodeSelector sets the node selector of the statefulset pod template, e.g. to move the proxies
+// and targets to separate node pools. The pods are rescheduled as the statefulset rolls them out.
+func (c *K8sClient) UpdateStatefulSetNodeSelector(ctx context.Context, name types.NamespacedName, selector map[string]string,
+	opts ...client.UpdateOption) (updated bool, err error) {
+	return c.UpdateWithRetry(ctx, name, func(ss *apiv1.StatefulSet) bool {
+		current := ss.Spec.Template.Spec.NodeSelector
+		if (len(current) == 0 && len(selector) == 0) || equality.Semantic.DeepEqual(current, selector) {
+			return false
+		}
+		ss.Spec.Template.Spec.NodeSelector = selector
+		return true
+	}, opts...)
+}
+
+// RemoveStatefulSetInitContainer removes the init container with the given name from the statefulset pod template, if present.
+func (c *K8sClient) RemoveStatefulSetInitContainer(ctx context.Context, name types.NamespacedName, container string,
+	opts ...client.UpdateOption) (updated bool, err error) {
+	return c.UpdateWithRetry(ctx, name, func(ss *apiv1.StatefulSet) bool {
+		initContainers := ss.Spec.Template.Spec.InitContainers
+		for idx := range initContainers {
+			if initContainers[idx].Name == container {
+				ss.Spec.Template.Spec.InitContainers = append(initContainers[:idx], initContainers[idx+1:]...)
+				return true
+			}
+		}
+		return false
+	}, opts...)
+}
+
+// PatchStatefulSet applies the patch (e.g. strategic merge or JSON patch) to the statefulset,
+// avoiding the read-modify-write cycle of `Update`.
+func (c *K8sClient) PatchStatefulSet(ctx context.Context, name types.NamespacedName, patch client.Patch) (*apiv1.StatefulSet, error) {
+	ss := &apiv1.StatefulSet{}
+	ss.SetName(name.Name)
+	ss.SetNamespace(name.Namespace)
+	err := c.client.Patch(ctx, ss, patch)
+	return ss, err
+}
+
+// SetStatefulSetUpdatePartition sets the rolling update partition of the statefulset;
+// only pods with ordinal >= `partition` are updated when the pod template changes.
+func (c *K8sClient) SetStatefulSetUpdatePartition(ctx context.Context, name types.NamespacedName, partition int32) error {
+	patch := fmt.Sprintf(`{"spec":{"updateStrategy":{"type":%q,"rollingUpdate":{"partition":%d}}}}`,
+		apiv1.RollingUpdateStatefulSetStrategyType, partition)
+	_, err := c.PatchStatefulSet(ctx, name, client.RawPatch(types.MergePatchType, []byte(patch)))
+	return err
+}
+
+// ScaleStatefulSet sets the replicas through the `scale` subresource, so that it composes with
+// other controllers scaling the statefulset the same way (e.g. HPA).
+func (c *K8sClient) ScaleStatefulSet(ctx context.Context, name types.NamespacedName, replicas int32) error {
+	patch := fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas)
+	_, err := c.clientset.AppsV1().StatefulSets(name.Namespace).Patch(ctx, name.Name,
+		types.MergePatchType, []byte(patch), metav1.PatchOptions{}, "scale")
+	return err
+}
+
+// AddFinalizer adds the finalizer to the object unless it's already present.
+// The change is sent as a merge patch, so that concurrent edits to the rest of the object are preserved.
+func (c *K8sClient) AddFinalizer(ctx context.Context, obj client.Object, finalizer string) (updated bool, err error) {
+	if controllerutil.ContainsFinalizer(obj, finalizer) {
+		return
+	}
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	controllerutil.AddFinalizer(obj, finalizer)
+	if err = c.client.Patch(ctx, obj, patch); err != nil {
+		return
+	}
+	return true, nil
+}
+
+// RemoveFinalizer removes the finalizer from the object if it's present.
+// The change is sent as a merge patch, so that concurrent edits to the rest of the object are preserved.
+func (c *K8sClient) RemoveFinalizer(ctx context.Context, obj client.Object, finalizer string) (updated bool, err error) {
+	if !controllerutil.ContainsFinalizer(obj, finalizer) {
+		return
+	}
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	controllerutil.RemoveFinalizer(obj, finalizer)
+	if err = c.client.Patch(ctx, obj, patch); err != nil {
+		return
+	}
+	return true, nil
+}
+
+// AcquireTopologyLock acquires the annotation-based lease on the AIStore CR for the disruptive `operation`,
+// so that only one of scale, upgrade or decommission proceeds at a time. Acquiring the lock already held by
+// the same operation succeeds and renews it; if another operation holds an unexpired lock, acquired=false.
+// The patch uses optimistic locking, so a concurrent acquire results in a conflict error.
+func (c *K8sClient) AcquireTopologyLock(ctx context.Context, ais *aisv1.AIStore, operation string) (acquired bool, err error) {
+	holder, held := ais.Annotations[AnnotationTopologyLock]
+	if held && holder != operation && !topologyLockExpired(ais) {
+		return false, nil
+	}
+	patch := client.MergeFromWithOptions(ais.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	metav1.SetMetaDataAnnotation(&ais.ObjectMeta, AnnotationTopologyLock, operation)
+	metav1.SetMetaDataAnnotation(&ais.ObjectMeta, AnnotationTopologyLockTime, time.Now().UTC().Format(time.RFC3339))
+	if err = c.client.Patch(ctx, ais, patch); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ReleaseTopologyLock releases the topology lock if it's held by `operation`.
+func (c *K8sClient) ReleaseTopologyLock(ctx context.Context, ais *aisv1.AIStore, operation string) error {
+	if ais.Annotations[AnnotationTopologyLock] != operation {
+		return nil
+	}
+	patch := client.MergeFromWithOptions(ais.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	delete(ais.Annotations, AnnotationTopologyLock)
+	delete(ais.Annotations, AnnotationTopologyLockTime)
+	return c.client.Patch(ctx, ais, patch)
+}
+
+// IsReconcilePaused checks whether reconciliation of the AIStore CR is paused (see AnnotationPaused).
+func IsReconcilePaused(ais *aisv1.AIStore) bool {
+	paused, err := strconv.ParseBool(ais.Annotations[AnnotationPaused])
+	return err == nil && paused
+}
+
+func topologyLockExpired(ais *aisv1.AIStore) bool {
+	acquiredAt, err := time.Parse(time.RFC3339, ais.Annotations[AnnotationTopologyLockTime])
+	return err != nil || time.Since(acquiredAt) > TopologyLockTTL
+}
+
+func (c *K8sClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return c.client.Create(ctx, obj, opts...)
+}
+
+// CreateResourceIfNotExists creates the resource, controlled by `owner` if set. With `client.DryRunAll`
+// nothing is persisted and `res` is filled with the would-be result returned by the API server.
+func (c *K8sClient) CreateResourceIfNotExists(ctx context.Context, owner *aisv1.AIStore, res client.Object,
+	opts ...client.CreateOption) (exists bool, err error) {
+	return c.CreateResourceWithOwner(ctx, owner, res, true /*controller*/, opts...)
+}
+
+// CreateResourceWithOwner creates the resource with an owner reference to `owner`, if set. Unless `controller`
+// is set, the owner reference is non-controlling, so that the resource (e.g. shared between AIS clusters)
+// can still be controlled or co-owned by others.
+func (c *K8sClient) CreateResourceWithOwner(ctx context.Context, owner *aisv1.AIStore, res client.Object, controller bool,
+	opts ...client.CreateOption) (exists bool, err error) {
+	if owner != nil {
+		res.SetNamespace(owner.Namespace)
+		if controller {
+			err = controllerutil.SetControllerReference(owner, res, c.scheme)
+		} else {
+			err = controllerutil.SetOwnerReference(owner, res, c.scheme)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	err = c.client.Create(ctx, res, opts...)
+	exists = err != nil && apierrors.IsAlreadyExists(err)
+	if exists {
+		err = nil
+	} else if err == nil && owner != nil && !isDryRun(opts) {
+		c.RecordEvent(owner, corev1.EventTypeNormal, EventReasonResourceCreated, "Created "+c.describe(res))
+	}
+	return
+}
+
+func (c *K8sClient) CreateSecretIfNotExists(ctx context.Context, owner *aisv1.AIStore, secret *corev1.Secret) (exists bool, err error) {
+	return c.CreateResourceIfNotExists(ctx, owner, secret)
+}
+
+func (c *K8sClient) CreateIngressIfNotExists(ctx context.Context, owner *aisv1.AIStore, ing *networkingv1.Ingress) (exists bool, err error) {
+	return c.CreateResourceIfNotExists(ctx, owner, ing)
+}
+
+func (c *K8sClient) CreateServiceAccountIfNotExists(ctx context.Context, owner *aisv1.AIStore, sa *corev1.ServiceAccount) (exists bool, err error) {
+	return c.CreateResourceIfNotExists(ctx, owner, sa)
+}
+
+func (c *K8sClient) CreateRoleIfNotExists(ctx context.Context, owner *aisv1.AIStore, role *rbacv1.Role) (exists bool, err error) {
+	return c.CreateResourceIfNotExists(ctx, owner, role)
+}
+
+func (c *K8sClient) CreateRoleBindingIfNotExists(ctx context.Context, owner *aisv1.AIStore, rb *rbacv1.RoleBinding) (exists bool, err error) {
+	return c.CreateResourceIfNotExists(ctx, owner, rb)
+}
+
+// EnsureImagePullSecret creates (or updates) the `kubernetes.io/dockerconfigjson` Secret owned by `owner`
+// holding the registry credentials, and returns its name for the `imagePullSecrets` of the pod template.
+func (c *K8sClient) EnsureImagePullSecret(ctx context.Context, owner *aisv1.AIStore, dockerConfigJSON []byte) (name string, err error) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: owner.Name + "-pull-secret"}}
+	_, err = c.CreateOrUpdate(ctx, owner, secret, func() error {
+		secret.Type = corev1.SecretTypeDockerConfigJson
+		secret.Data = map[string][]byte{corev1.DockerConfigJsonKey: dockerConfigJSON}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return secret.Name, nil
+}
+
+// CreatePDBIfNotExists creates a PodDisruptionBudget, requiring `minAvailable` of the pods matching
+// the selector to remain available during voluntary evictions (e.g. `kubectl drain`).
+func (c *K8sClient) CreatePDBIfNotExists(ctx context.Context, owner *aisv1.AIStore, name types.NamespacedName,
+	selector map[string]string, minAvailable intstr.IntOrString) (exists bool, err error) {
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.Name,
+			Namespace: name.Namespace,
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			Selector:     &metav1.LabelSelector{MatchLabels: selector},
+		},
+	}
+	return c.CreateResourceIfNotExists(ctx, owner, pdb)
+}
+
+// CreateHeadlessServiceIfNotExists creates a headless Service (`clusterIP: None`) selecting the pods,
+// which a statefulset requires (as its `serviceName`) for stable per-pod DNS names.
+func (c *K8sClient) CreateHeadlessServiceIfNotExists(ctx context.Context, owner *aisv1.AIStore, name types.NamespacedName,
+	selector map[string]string, ports []corev1.ServicePort) (exists bool, err error) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.Name,
+			Namespace: name.Namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Selector:  selector,
+			Ports:     ports,
+		},
+	}
+	return c.CreateResourceIfNotExists(ctx, owner, svc)
+}
+
+// CreateOrUpdate creates the resource if it doesn't exist, otherwise converges the existing one to the desired state.
+// `mutate` is invoked on the current state of the resource (or the empty object when creating) and should set
+// only the fields owned by the operator, preserving server-managed fields. The controller reference to `owner`
+// is set on the resource, if `owner` is provided.
+// `mutate` is also invoked once on `res` as passed, to stamp the hash of the desired state (see ComputeSpecHash),
+// so it must be idempotent.
+func (c *K8sClient) CreateOrUpdate(ctx context.Context, owner *aisv1.AIStore, res client.Object,
+	mutate func() error) (controllerutil.OperationResult, error) {
+	if owner != nil {
+		res.SetNamespace(owner.Namespace)
+	}
+	hash, err := desiredSpecHash(res, mutate)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	return controllerutil.CreateOrUpdate(ctx, c.client, res, func() error {
+		if mutate != nil {
+			if err := mutate(); err != nil {
+				return err
+			}
+		}
+		annotations := res.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string, 1)
+		}
+		annotations[AnnotationSpecHash] = hash
+		res.SetAnnotations(annotations)
+		if owner == nil {
+			return nil
+		}
+		return controllerutil.SetControllerReference(owner, res, c.scheme)
+	})
+}
+
+// ComputeSpecHash returns a hash of the desired state of the resource, i.e. everything but its metadata
+// and status (e.g. `spec`, or `data` of a ConfigMap). CreateOrUpdate stamps it on the resource as
+// AnnotationSpecHash, so a matching hash means the resource doesn't need to be diffed and updated.
+func ComputeSpecHash(obj client.Object) string {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return ""
+	}
+	for _, field := range []string{"apiVersion", "kind", "metadata", "status"} {
+		delete(content, field)
+	}
+	// Map keys are marshaled in sorted order, so the encoding is deterministic.
+	b, err := json.Marshal(content)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// desiredSpecHash hashes the desired state of the resource, by applying `mutate` to `res` as passed rather than
+// to its current state, which carries the fields defaulted by the server (e.g. `clusterIP` of a Service).
+// `res` is restored afterwards.
+func desiredSpecHash(res client.Object, mutate func() error) (string, error) {
+	base := res.DeepCopyObject()
+	if mutate != nil {
+		if err := mutate(); err != nil {
+			return "", err
+		}
+	}
+	hash := ComputeSpecHash(res)
+	reflect.ValueOf(res).Elem().Set(reflect.ValueOf(base).Elem())
+	return hash, nil
+}
+
+// SpecHashMatches checks whether the resource was last converged to the desired state with the given hash.
+func SpecHashMatches(obj client.Object, hash string) bool {
+	return hash != "" && obj.GetAnnotations()[AnnotationSpecHash] == hash
+}
+
+func (c *K8sClient) CheckIfNamespaceExists(ctx context.Context, name string) (exists bool, err error) {
+	ns := &corev1.Namespace{}
+	err = c.client.Get(ctx, types.NamespacedName{Name: name}, ns)
+	if err == nil {
+		exists = true
+	} else if apierrors.IsNotFound(err) {
+		err = nil
+	}
+	return exists, err
+}
+
+// CreateNamespaceIfNotExists creates the namespace with the given labels.
+// Namespaces are cluster-scoped, so unlike other resources it has no owner reference to the AIStore CR.
+func (c *K8sClient) CreateNamespaceIfNotExists(ctx context.Context, name string, labels map[string]string) (exists bool, err error) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+	}
+	return c.CreateResourceIfNotExists(ctx, nil, ns)
+}
+
+/////////////////////////////////
+//       Delete resources      //
+////////////////////////////////
+
+// DeleteResourceIfExists deletes an existing resource. It doesn't fail if the resource does not exist.
+// The deletion is recorded as an event on `owner` if set, otherwise on the AIStore CR controlling the resource, if any.
+func (c *K8sClient) DeleteResourceIfExists(ctx context.Context, owner *aisv1.AIStore, obj client.Object,
+	opts ...client.DeleteOption) (existed bool, err error) {
+	err = c.client.Delete(ctx, obj, opts...)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		err = fmt.Errorf("failed to delete %s: %q (namespace %q); err %v", obj.GetObjectKind(), obj.GetName(), obj.GetNamespace(), err)
+		c.recordOwnerEvent(owner, obj, corev1.EventTypeWarning, EventReasonFailedDelete, err.Error())
+		return false, err
+	}
+	c.recordOwnerEvent(owner, obj, corev1.EventTypeNormal, EventReasonResourceDeleted, "Deleted "+c.describe(obj))
+	return true, nil
+}
+
+func (c *K8sClient) DeleteServiceIfExists(ctx context.Context, owner *aisv1.AIStore, name types.NamespacedName) (existed bool, err error) {
+	svc := &corev1.Service{}
+	svc.SetName(name.Name)
+	svc.SetNamespace(name.Namespace)
+	return c.DeleteResourceIfExists(ctx, owner, svc)
+}
+
+func (c *K8sClient) DeleteIngressIfExists(ctx context.Context, owner *aisv1.AIStore, name types.NamespacedName) (existed bool, err error) {
+	ing := &networkingv1.Ingress{}
+	ing.SetName(name.Name)
+	ing.SetNamespace(name.Namespace)
+	return c.DeleteResourceIfExists(ctx, owner, ing)
+}
+
+// DeleteAllServicesIfExist deletes all the services matching the labels and optional `opts`, e.g. field selectors.
+func (c *K8sClient) DeleteAllServicesIfExist(ctx context.Context, namespace string, labels client.MatchingLabels,
+	opts ...client.ListOption) (anyExisted bool, err error) {
+	svcs := &corev1.ServiceList{}
+	err = c.client.List(ctx, svcs, append([]client.ListOption{client.InNamespace(namespace), labels}, opts...)...)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			err = nil
+		}
+		return
+	}
+
+	for i := range svcs.Items {
+		var existed bool
+		existed, err = c.DeleteResourceIfExists(ctx, nil, &svcs.Items[i])
+		if err != nil {
+			return
+		}
+		anyExisted = anyExisted || existed
+	}
+	return
+}
+
+// DeleteAllPVCsIfExist deletes all the PVCs matching the labels (and optional `opts`, e.g. field selectors),
+// regardless of their owner.
+// PVCs controlled by something else than `owner` are deleted too, but a warning is logged for each of them.
+// Prefer `DeleteAllOwnedPVCsIfExist` when multiple AIS clusters share a namespace.
+// The PVCs, created by the statefulset, aren't controlled by the AIStore CR, so the deletions are recorded
+// as events on `owner`.
+func (c *K8sClient) DeleteAllPVCsIfExist(ctx context.Context, owner *aisv1.AIStore, namespace string,
+	labels client.MatchingLabels, opts ...client.ListOption) (anyExisted bool, err error) {
+	return c.deleteAllPVCsIfExist(ctx, owner, namespace, labels, opts, func(pvc *corev1.PersistentVolumeClaim) bool {
+		if ref := metav1.GetControllerOf(pvc); ref != nil && (owner == nil || ref.UID != owner.UID) {
+			c.logger().Info("Deleting PVC controlled by another owner",
+				"pvc", pvc.Name, "namespace", pvc.Namespace, "owner", ref.Kind+"/"+ref.Name)
+		}
+		return true
+	})
+}
+
+// DeleteAllOwnedPVCsIfExist deletes the PVCs matching the labels that have an owner reference to `ownerUID`
+// (e.g. AIStore CR), leaving PVCs of other AIS clusters in the same namespace intact.
+func (c *K8sClient) DeleteAllOwnedPVCsIfExist(ctx context.Context, namespace string, labels client.MatchingLabels,
+	ownerUID types.UID, opts ...client.ListOption) (anyExisted bool, err error) {
+	return c.deleteAllPVCsIfExist(ctx, nil, namespace, labels, opts, func(pvc *corev1.PersistentVolumeClaim) bool {
+		for _, ref := range pvc.OwnerReferences {
+			if ref.UID == ownerUID {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// DeletePVCsForOrdinalsAbove deletes the PVCs created from the statefulset's volume claim templates for pods
+// with ordinal >= `replicas`, which the statefulset leaves behind on scale-down.
+// As a guard against deleting data that's still needed, it fails unless the statefulset has already been
+// scaled down to `replicas` (which happens only after the targets were decommissioned) and the pods are gone.
+// The statefulset is read live, bypassing the cache. The deletions are recorded as events on `owner`, if set.
+func (c *K8sClient) DeletePVCsForOrdinalsAbove(ctx context.Context, owner *aisv1.AIStore, name types.NamespacedName,
+	replicas int32) (anyExisted bool, err error) {
+	ss, err := c.GetStatefulSetLive(ctx, name)
+	if err != nil {
+		return
+	}
+	if current := statefulSetReplicas(ss); current > replicas || ss.Status.Replicas > replicas {
+		err = fmt.Errorf("statefulset %q not scaled down to %d replicas yet (spec %d, status %d)",
+			name, replicas, current, ss.Status.Replicas)
+		return
+	}
+	return c.deleteAllPVCsIfExist(ctx, owner, name.Namespace, nil, nil, func(pvc *corev1.PersistentVolumeClaim) bool {
+		// PVC names have the format `<template>-<statefulset>-<ordinal>`.
+		for i := range ss.Spec.VolumeClaimTemplates {
+			ordinal, err := podOrdinal(pvc.Name, ss.Spec.VolumeClaimTemplates[i].Name+"-"+ss.Name)
+			if err == nil {
+				return ordinal >= int(replicas)
+			}
+		}
+		return false
+	})
+}
+
+func (c *K8sClient) deleteAllPVCsIfExist(ctx context.Context, owner *aisv1.AIStore, namespace string,
+	labels client.MatchingLabels, opts []client.ListOption, filter func(pvc *corev1.PersistentVolumeClaim) bool) (anyExisted bool, err error) {
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	err = c.client.List(ctx, pvcs, append([]client.ListOption{client.InNamespace(namespace), labels}, opts...)...)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			err = nil
+		}
+		return
+	}
+
+	for i := range pvcs.Items {
+		if !filter(&pvcs.Items[i]) {
+			continue
+		}
+		var existed bool
+		existed, err = c.DeleteResourceIfExists(ctx, owner, &pvcs.Items[i])
+		if err != nil {
+			return
+		}
+		anyExisted = anyExisted || existed
+	}
+	return
+}
+
+func (c *K8sClient) DeleteStatefulSetIfExists(ctx context.Context, owner *aisv1.AIStore, name types.NamespacedName) (existed bool, err error) {
+	ss := &apiv1.StatefulSet{}
+	ss.SetName(name.Name)
+	ss.SetNamespace(name.Namespace)
+	return c.DeleteResourceIfExists(ctx, owner, ss)
+}
+
+// RecreateStatefulSetPreservingPods replaces the statefulset spec, including the fields that can't be updated in place
+// (e.g. `volumeClaimTemplates`, `serviceName`). The statefulset is deleted orphaning its pods (`--cascade=orphan`) and
+// recreated with the same metadata, so the statefulset controller adopts the running pods back. The selector must
+// not change, otherwise the pods wouldn't be adopted.
+func (c *K8sClient) RecreateStatefulSetPreservingPods(ctx context.Context, name types.NamespacedName,
+	newSpec apiv1.StatefulSetSpec) error {
+	current, err := c.GetStatefulSet(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !equality.Semantic.DeepEqual(current.Spec.Selector, newSpec.Selector) {
+		return fmt.Errorf("statefulset %q selector must not change, the existing pods wouldn't be adopted", name)
+	}
+	_, err = c.DeleteResourceIfExists(ctx, nil, current, client.PropagationPolicy(metav1.DeletePropagationOrphan))
+	if err != nil {
+		return err
+	}
+	if err = c.waitForStatefulSetDeleted(ctx, name); err != nil {
+		return err
+	}
+	ss := &apiv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name.Name,
+			Namespace:       name.Namespace,
+			Labels:          current.Labels,
+			Annotations:     current.Annotations,
+			OwnerReferences: current.OwnerReferences,
+		},
+		Spec: newSpec,
+	}
+	return c.client.Create(ctx, ss)
+}
+
+// waitForStatefulSetDeleted waits for the garbage collector to orphan the pods and remove the statefulset.
+func (c *K8sClient) waitForStatefulSetDeleted(ctx context.Context, name types.NamespacedName) error {
+	ctxBack, cancel := context.WithTimeout(ctx, c.waitTimeout(0))
+	defer cancel()
+	for {
+		_, err := c.GetStatefulSetLive(ctxBack, name)
+		if errors.Is(err, ErrStatefulSetNotFound) {
+			return nil
+		}
+		if err != nil && ctxBack.Err() == nil {
+			return err
+		}
+		select {
+		case <-ctxBack.Done():
+			return fmt.Errorf("statefulset %q not deleted: %w", name, ctxBack.Err())
+		case <-time.After(c.pollInterval()):
+		}
+	}
+}
+
+// DeleteAllStatefulSetsIfExist deletes all the statefulsets matching the labels and optional `opts`,
+// independent of their naming scheme.
+func (c *K8sClient) DeleteAllStatefulSetsIfExist(ctx context.Context, namespace string, labels client.MatchingLabels,
+	opts ...client.ListOption) (anyExisted bool, err error) {
+	list := &apiv1.StatefulSetList{}
+	err = c.client.List(ctx, list, append([]client.ListOption{client.InNamespace(namespace), labels}, opts...)...)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			err = nil
+		}
+		return
+	}
+
+	for i := range list.Items {
+		var existed bool
+		existed, err = c.DeleteResourceIfExists(ctx, nil, &list.Items[i])
+		if err != nil {
+			return
+		}
+		anyExisted = anyExisted || existed
+	}
+	return
+}
+
+func (c *K8sClient) DeleteDaemonSetIfExists(ctx context.Context, owner *aisv1.AIStore, name types.NamespacedName) (existed bool, err error) {
+	ds := &apiv1.DaemonSet{}
+	ds.SetName(name.Name)
+	ds.SetNamespace(name.Namespace)
+	return c.DeleteResourceIfExists(ctx, owner, ds)
+}
+
+func (c *K8sClient) DeleteConfigMapIfExists(ctx context.Context, owner *aisv1.AIStore, name types.NamespacedName) (existed bool, err error) {
+	ss := &corev1.ConfigMap{}
+	ss.SetName(name.Name)
+	ss.SetNamespace(name.Namespace)
+	return c.DeleteResourceIfExists(ctx, owner, ss)
+}
+
+func (c *K8sClient) DeleteSecretIfExists(ctx context.Context, owner *aisv1.AIStore, name types.NamespacedName) (existed bool, err error) {
+	secret := &corev1.Secret{}
+	secret.SetName(name.Name)
+	secret.SetNamespace(name.Namespace)
+	return c.DeleteResourceIfExists(ctx, owner, secret)
+}
+
+// DeleteAllConfigMapsIfExist deletes all the ConfigMaps matching the labels and optional `opts`.
+func (c *K8sClient) DeleteAllConfigMapsIfExist(ctx context.Context, namespace string, labels client.MatchingLabels,
+	opts ...client.ListOption) (anyExisted bool, err error) {
+	cms := &corev1.ConfigMapList{}
+	err = c.client.List(ctx, cms, append([]client.ListOption{client.InNamespace(namespace), labels}, opts...)...)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			err = nil
+		}
+		return
+	}
+
+	for i := range cms.Items {
+		var existed bool
+		existed, err = c.DeleteResourceIfExists(ctx, nil, &cms.Items[i])
+		if err != nil {
+			return
+		}
+		anyExisted = anyExisted || existed
+	}
+	return
+}
+
+// DeleteCompletedJobs deletes the jobs matching the labels that finished (either completed or failed)
+// more than `olderThan` ago, along with their pods. Returns the number of deleted jobs.
+func (c *K8sClient) DeleteCompletedJobs(ctx context.Context, namespace string, labels client.MatchingLabels,
+	olderThan time.Duration) (deleted int, err error) {
+	jobs, err := c.ListJobs(ctx, namespace, labels)
+	if err != nil {
+		return
+	}
+	for i := range jobs.Items {
+		finishedAt, finished := jobFinishTime(&jobs.Items[i])
+		if !finished || time.Since(finishedAt) < olderThan {
+			continue
+		}
+		var existed bool
+		existed, err = c.DeleteResourceIfExists(ctx, nil, &jobs.Items[i], client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil {
+			return
+		}
+		if existed {
+			deleted++
+		}
+	}
+	return
+}
+
+func jobFinishTime(job *batchv1.Job) (time.Time, bool) {
+	for _, cond := range job.Status.Conditions {
+		if (cond.Type == batchv1.JobComplete || cond.Type == batchv1.JobFailed) && cond.Status == corev1.ConditionTrue {
+			if job.Status.CompletionTime != nil {
+				return job.Status.CompletionTime.Time, true
+			}
+			return cond.LastTransitionTime.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (c *K8sClient) DeletePDBIfExists(ctx context.Context, owner *aisv1.AIStore, name types.NamespacedName) (existed bool, err error) {
+	pdb := &policyv1.PodDisruptionBudget{}
+	pdb.SetName(name.Name)
+	pdb.SetNamespace(name.Namespace)
+	return c.DeleteResourceIfExists(ctx, owner, pdb)
+}
+
+func (c *K8sClient) DeletePodIfExists(ctx context.Context, owner *aisv1.AIStore, name types.NamespacedName) (err error) {
+	_, err = c.deletePodIfExists(ctx, owner, name)
+	return
+}
+
+// DeletePodWithGracePeriod deletes the pod (if exists), allowing it `gracePeriodSeconds` to terminate,
+// e.g. for AIS daemon to flush in-flight writes.
+func (c *K8sClient) DeletePodWithGracePeriod(ctx context.Context, owner *aisv1.AIStore, name types.NamespacedName,
+	gracePeriodSeconds int64) (existed bool, err error) {
+	return c.deletePodIfExists(ctx, owner, name, client.GracePeriodSeconds(gracePeriodSeconds))
+}
+
+func (c *K8sClient) deletePodIfExists(ctx context.Context, owner *aisv1.AIStore, name types.NamespacedName,
+	opts ...client.DeleteOption) (existed bool, err error) {
+	pod := &corev1.Pod{}
+	pod.SetName(name.Name)
+	pod.SetNamespace(name.Namespace)
+	return c.DeleteResourceIfExists(ctx, owner, pod, opts...)
+}
+
+// WaitForPodReady waits for the pod to report the `Ready` condition, polling with exponential backoff
+// starting at the client poll interval (see ClientOptions) and capped at `MaxRetryInterval`.
+func (c *K8sClient) WaitForPodReady(ctx context.Context, name types.NamespacedName, timeout time.Duration) error {
+	return c.WaitForPodReadyWithInterval(ctx, name, timeout, c.pollInterval())
+}
+
+// WaitForPodReadyWithInterval waits for the pod to report the `Ready` condition, polling with exponential backoff
+// starting at `retryInterval` and capped at `MaxRetryInterval`.
+// A pod that doesn't exist yet is waited for; any other error is returned immediately.
+func (c *K8sClient) WaitForPodReadyWithInterval(ctx context.Context, name types.NamespacedName,
+	timeout, retryInterval time.Duration) error {
+	return c.waitForPod(ctx, name, timeout, retryInterval, isPodReady)
+}
+
+// WaitForPodContainerReady waits for the given container of the pod to be ready, regardless of the
+// readiness of other containers (e.g. sidecars).
+func (c *K8sClient) WaitForPodContainerReady(ctx context.Context, name types.NamespacedName, container string,
+	timeout time.Duration) error {
+	return c.waitForPod(ctx, name, timeout, c.pollInterval(), func(pod *corev1.Pod) bool {
+		for i := range pod.Status.ContainerStatuses {
+			if pod.Status.ContainerStatuses[i].Name == container {
+				return pod.Status.ContainerStatuses[i].Ready
+			}
+		}
+		return false
+	})
+}
+
+func (c *K8sClient) waitForPod(ctx context.Context, name types.NamespacedName, timeout, retryInterval time.Duration,
+	ready func(*corev1.Pod) bool) error {
+	ctxBack, cancel := context.WithTimeout(ctx, c.waitTimeout(timeout))
+	defer cancel()
+	backoff := newRetryBackoff(c.retryInterval(retryInterval))
+	for {
+		pod, err := c.GetPodByName(ctxBack, name)
+		if err == nil {
+			if ready(pod) {
+				return nil
+			}
+		} else if !apierrors.IsNotFound(err) {
+			return err
+		}
+		select {
+		case <-ctxBack.Done():
+			return ctxBack.Err()
+		case <-time.After(backoff.Step()):
+		}
+	}
+}
+
+// WaitForPodDeleted waits until the pod no longer exists, polling at the client poll interval.
+// On timeout, the returned error includes the last observed phase, e.g. to tell if the pod was stuck terminating.
+func (c *K8sClient) WaitForPodDeleted(ctx context.Context, name types.NamespacedName, timeout time.Duration) error {
+	ctxBack, cancel := context.WithTimeout(ctx, c.waitTimeout(timeout))
+	defer cancel()
+	var lastPod *corev1.Pod
+	for {
+		pod, err := c.GetPodByName(ctxBack, name)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err == nil {
+			lastPod = pod
+		} else if ctxBack.Err() == nil {
+			return err
+		}
+		select {
+		case <-ctxBack.Done():
+			if lastPod == nil {
+				return fmt.Errorf("pod %q not deleted: %w", name, ctxBack.Err())
+			}
+			return fmt.Errorf("pod %q not deleted (phase %q, terminating %t): %w",
+				name, lastPod.Status.Phase, lastPod.DeletionTimestamp != nil, ctxBack.Err())
+		case <-time.After(c.pollInterval()):
+		}
+	}
+}
+
+// SetPodAnnotation sets the annotation on the pod, patching it only if the value differs.
+func (c *K8sClient) SetPodAnnotation(ctx context.Context, name types.NamespacedName, key, value string) (updated bool, err error) {
+	pod, err := c.GetPodByName(ctx, name)
+	if err != nil {
+		return
+	}
+	return c.setPodAnnotation(ctx, pod, key, value)
+}
+
+// SetPodsAnnotation sets the annotation on all pods in the namespace matching the labels,
+// e.g. to stamp the config revision. Returns the number of pods that were updated.
+func (c *K8sClient) SetPodsAnnotation(ctx context.Context, namespace string, labels client.MatchingLabels,
+	key, value string) (updated int, err error) {
+	pods, err := c.ListPods(ctx, namespace, labels)
+	if err != nil {
+		return
+	}
+	for i := range pods.Items {
+		podUpdated, err := c.setPodAnnotation(ctx, &pods.Items[i], key, value)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return updated, err
+		}
+		if podUpdated {
+			updated++
+		}
+	}
+	return
+}
+
+// PatchPodsByLabel applies the JSON merge patch (RFC 7386) to each pod in the namespace matching the labels,
+// e.g. to re-stamp labels on operator upgrade. Patching continues past failures, which are returned per pod name;
+// pods deleted in the meantime are skipped. `err` is set only if the pods couldn't be listed.
+func (c *K8sClient) PatchPodsByLabel(ctx context.Context, namespace string, labels client.MatchingLabels,
+	patch []byte) (podErrs map[string]error, err error) {
+	pods, err := c.ListPods(ctx, namespace, labels)
+	if err != nil {
+		return
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		patchErr := c.client.Patch(ctx, pod, client.RawPatch(types.MergePatchType, patch))
+		if patchErr == nil || apierrors.IsNotFound(patchErr) {
+			continue
+		}
+		if podErrs == nil {
+			podErrs = make(map[string]error)
+		}
+		podErrs[pod.Name] = patchErr
+	}
+	return
+}
+
+func (c *K8sClient) setPodAnnotation(ctx context.Context, pod *corev1.Pod, key, value string) (updated bool, err error) {
+	if current, ok := pod.Annotations[key]; ok && current == value {
+		return
+	}
+	patch := client.MergeFrom(pod.DeepCopy())
+	metav1.SetMetaDataAnnotation(&pod.ObjectMeta, key, value)
+	if err = c.client.Patch(ctx, pod, patch); err != nil {
+		return
+	}
+	return true, nil
+}
+
+// MarkTargetMaintenance sets (or removes) the maintenance annotation on the target pod.
+func (c *K8sClient) MarkTargetMaintenance(ctx context.Context, name types.NamespacedName, enabled bool) (updated bool, err error) {
+	pod, err := c.GetPodByName(ctx, name)
+	if err != nil {
+		return
+	}
+	if _, marked := pod.Annotations[AnnotationMaintenance]; marked == enabled {
+		return
+	}
+	patch := client.MergeFrom(pod.DeepCopy())
+	if enabled {
+		metav1.SetMetaDataAnnotation(&pod.ObjectMeta, AnnotationMaintenance, "true")
+	} else {
+		delete(pod.Annotations, AnnotationMaintenance)
+	}
+	if err = c.client.Patch(ctx, pod, patch); err != nil {
+		return
+	}
+	return true, nil
+}
+
+// WaitForTargetDrained waits until the target pod reports not ready, i.e. its readiness probe fails
+// once there are no in-flight requests and it no longer receives traffic through services.
+func (c *K8sClient) WaitForTargetDrained(ctx context.Context, name types.NamespacedName, timeout time.Duration) error {
+	ctxBack, cancel := context.WithTimeout(ctx, c.waitTimeout(timeout))
+	defer cancel()
+	for {
+		pod, err := c.GetPodByName(ctxBack, name)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !isPodReady(pod) {
+			return nil
+		}
+		select {
+		case <-ctxBack.Done():
+			return fmt.Errorf("target pod %q not drained: %w", name, ctxBack.Err())
+		case <-time.After(c.pollInterval()):
+		}
+	}
+}
+
+// WaitForPVCsBound waits until there's at least one PVC matching the labels and all of them reach the `Bound` phase.
+// The PVCs don't exist yet right after the statefulset is created, so an empty list keeps it waiting.
+// On timeout, the returned error names the PVCs that are still not bound, e.g. due to a misconfigured StorageClass.
+func (c *K8sClient) WaitForPVCsBound(ctx context.Context, namespace string, labels client.MatchingLabels, timeout time.Duration) error {
+	ctxBack, cancel := context.WithTimeout(ctx, c.waitTimeout(timeout))
+	defer cancel()
+	var (
+		notBound []string
+		found    bool
+	)
+	for {
+		pvcs := &corev1.PersistentVolumeClaimList{}
+		err := c.client.List(ctxBack, pvcs, client.InNamespace(namespace), labels)
+		if err != nil && !apierrors.IsNotFound(err) {
+			if ctxBack.Err() == nil {
+				return err
+			}
+		} else {
+			notBound = notBound[:0]
+			for i := range pvcs.Items {
+				if pvcs.Items[i].Status.Phase != corev1.ClaimBound {
+					notBound = append(notBound, pvcs.Items[i].Name)
+				}
+			}
+			found = len(pvcs.Items) > 0
+			if found && len(notBound) == 0 {
+				return nil
+			}
+		}
+		select {
+		case <-ctxBack.Done():
+			if !found {
+				return fmt.Errorf("no PVCs matching labels %v found: %w", labels, ctxBack.Err())
+			}
+			return fmt.Errorf("PVCs not bound %v: %w", notBound, ctxBack.Err())
+		case <-time.After(c.pollInterval()):
+		}
+	}
+}
+
+// WaitForLoadBalancer waits for the cloud provider to assign an ingress to the LoadBalancer service,
+// returning its IP (e.g. GCP, AWS NLB) or hostname (e.g. AWS ELB).
+func (c *K8sClient) WaitForLoadBalancer(ctx context.Context, name types.NamespacedName, timeout time.Duration) (string, error) {
+	ctxBack, cancel := context.WithTimeout(ctx, c.waitTimeout(timeout))
+	defer cancel()
+	for {
+		svc, err := c.GetServiceByName(ctxBack, name)
+		if err != nil && ctxBack.Err() == nil {
+			return "", err
+		}
+		if err == nil {
+			for _, ing := range svc.Status.LoadBalancer.Ingress {
+				if ing.IP != "" {
+					return ing.IP, nil
+				}
+				if ing.Hostname != "" {
+					return ing.Hostname, nil
+				}
+			}
+		}
+		select {
+		case <-ctxBack.Done():
+			return "", fmt.Errorf("no ingress assigned to LoadBalancer service %q: %w", name, ctxBack.Err())
+		case <-time.After(c.pollInterval()):
+		}
+	}
+}
+
+// GetReadyTargetEndpoints returns the sorted addresses of the ready endpoints of the service, read from its
+// EndpointSlices, e.g. to build the AIS seed-node list from the pods that are actually up rather than
+// assuming the ordinal-0 pod starts first. Endpoints with unknown readiness are considered ready.
+func (c *K8sClient) GetReadyTargetEndpoints(ctx context.Context, serviceName types.NamespacedName) ([]string, error) {
+	slices := &discoveryv1.EndpointSliceList{}
+	err := c.client.List(ctx, slices, client.InNamespace(serviceName.Namespace),
+		client.MatchingLabels{discoveryv1.LabelServiceName: serviceName.Name})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{})
+	addresses := make([]string, 0, len(slices.Items))
+	for i := range slices.Items {
+		for _, endpoint := range slices.Items[i].Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			for _, addr := range endpoint.Addresses {
+				if _, ok := seen[addr]; ok {
+					continue
+				}
+				seen[addr] = struct{}{}
+				addresses = append(addresses, addr)
+			}
+		}
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+// WaitForServiceEndpoints waits until the service has at least `minReady` ready endpoint addresses,
+// i.e. pods that actually serve the traffic sent to the service.
+func (c *K8sClient) WaitForServiceEndpoints(ctx context.Context, name types.NamespacedName, minReady int,
+	timeout time.Duration) error {
+	return c.waitForServiceEndpoints(ctx, name, minReady, timeout, c.pollInterval())
+}
+
+func (c *K8sClient) waitForServiceEndpoints(ctx context.Context, name types.NamespacedName, minReady int,
+	timeout, retryInterval time.Duration) error {
+	ctxBack, cancel := context.WithTimeout(ctx, c.waitTimeout(timeout))
+	defer cancel()
+	var ready int
+	for {
+		endpoints := &corev1.Endpoints{}
+		err := c.client.Get(ctxBack, name, endpoints)
+		if err != nil && !apierrors.IsNotFound(err) && ctxBack.Err() == nil {
+			return err
+		}
+		if err == nil {
+			ready = 0
+			for _, subset := range endpoints.Subsets {
+				ready += len(subset.Addresses)
+			}
+			if ready >= minReady {
+				return nil
+			}
+		}
+		select {
+		case <-ctxBack.Done():
+			return fmt.Errorf("service %q has %d ready endpoints, expected %d: %w", name, ready, minReady, ctxBack.Err())
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// WaitForStatefulSetReady waits until all the replicas of the statefulset are ready
+// and the statefulset controller has observed the latest generation, polling at the client poll interval.
+func (c *K8sClient) WaitForStatefulSetReady(ctx context.Context, name types.NamespacedName, timeout time.Duration) error {
+	return c.WaitForStatefulSetReadyWithInterval(ctx, name, timeout, c.pollInterval())
+}
+
+// WaitForStatefulSetReadyWithInterval waits for the statefulset to be ready, polling every `retryInterval`.
+// A statefulset that doesn't exist yet is waited for; any other error is returned immediately.
+// On timeout, the returned error describes the last observed replica counts.
+func (c *K8sClient) WaitForStatefulSetReadyWithInterval(ctx context.Context, name types.NamespacedName,
+	timeout, retryInterval time.Duration) error {
+	ctxBack, cancel := context.WithTimeout(ctx, c.waitTimeout(timeout))
+	defer cancel()
+	retryInterval = c.retryInterval(retryInterval)
+	var lastSS *apiv1.StatefulSet
+	for {
+		ss, err := c.GetStatefulSet(ctxBack, name)
+		if err == nil {
+			lastSS = ss
+			if ss.Status.ObservedGeneration == ss.Generation && ss.Status.ReadyReplicas == statefulSetReplicas(ss) {
+				return nil
+			}
+		} else if !errors.Is(err, ErrStatefulSetNotFound) && ctxBack.Err() == nil {
+			return err
+		}
+		select {
+		case <-ctxBack.Done():
+			if lastSS == nil {
+				return fmt.Errorf("statefulset %q not found: %w", name, ctxBack.Err())
+			}
+			return fmt.Errorf("statefulset %q not ready, %d/%d replicas ready (observed generation %d, generation %d): %w",
+				name, lastSS.Status.ReadyReplicas, statefulSetReplicas(lastSS),
+				lastSS.Status.ObservedGeneration, lastSS.Generation, ctxBack.Err())
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// statefulSetReplicas returns the desired number of replicas; `nil` defaults to 1 as per K8s API.
+func statefulSetReplicas(ss *apiv1.StatefulSet) int32 {
+	if ss.Spec.Replicas == nil {
+		return 1
+	}
+	return *ss.Spec.Replicas
+}
+
+func checkContainerIdx(ss *apiv1.StatefulSet, idx int) error {
+	if cnt := len(ss.Spec.Template.Spec.Containers); idx < 0 || idx >= cnt {
+		return fmt.Errorf("container index %d out of range (statefulset %q has %d container(s))", idx, ss.Name, cnt)
+	}
+	return nil
+}
+
+// isDryRun checks if the create options request a dry run, i.e. nothing is persisted.
+func isDryRun(opts []client.CreateOption) bool {
+	createOpts := &client.CreateOptions{}
+	createOpts.ApplyOptions(opts)
+	return len(createOpts.DryRun) > 0
+}
+
+// podOrdinal parses the ordinal index of the statefulset pod from its name, i.e. `<statefulset>-<ordinal>`.
+func podOrdinal(podName, ssName string) (int, error) {
+	suffix := strings.TrimPrefix(podName, ssName+"-")
+	ordinal, err := strconv.Atoi(suffix)
+	if suffix == podName || err != nil || ordinal < 0 {
+		return 0, fmt.Errorf("pod %q is not a pod of statefulset %q", podName, ssName)
+	}
+	return ordinal, nil
+}
+
+// newRetryBackoff returns a backoff doubling the interval on each step, up to `MaxRetryInterval`.
+// NOTE: once the cap is reached, `Step` keeps returning `MaxRetryInterval`.
+func newRetryBackoff(interval time.Duration) *wait.Backoff {
+	return &wait.Backoff{
+		Duration: interval,
+		Factor:   2,
+		Steps:    math.MaxInt32,
+		Cap:      MaxRetryInterval,
+	}
+}
+
+// isPodReady checks if the `Ready` condition of the pod is true.
+func isPodReady(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
 		}
 	}
+	return false
 }
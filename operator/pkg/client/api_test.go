@@ -0,0 +1,1582 @@
+// Package client contains wrapper for k8s client
+/*
+ * Copyright (c) 2021, NVIDIA CORPORATION. All rights reserved.
+ */
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apiv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	aisv1 "github.com/ais-operator/api/v1beta1"
+)
+
+const (
+	testNamespace = "ais-test"
+	testInterval  = 10 * time.Millisecond
+)
+
+func newTestStatefulSet(name string, replicas int32, images ...string) *apiv1.StatefulSet {
+	ss := &apiv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec:       apiv1.StatefulSetSpec{Replicas: &replicas},
+	}
+	for i, image := range images {
+		ss.Spec.Template.Spec.Containers = append(ss.Spec.Template.Spec.Containers,
+			corev1.Container{Name: fmt.Sprintf("container-%d", i), Image: image})
+	}
+	return ss
+}
+
+func newTestPod(name string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+}
+
+func newReadyTestPod(name string, containers ...corev1.ContainerStatus) *corev1.Pod {
+	pod := newTestPod(name, corev1.PodRunning)
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	pod.Status.ContainerStatuses = containers
+	return pod
+}
+
+var _ = Describe("K8sClient", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	Describe("WaitForPodReady", func() {
+		name := types.NamespacedName{Name: "pod-0", Namespace: testNamespace}
+
+		It("should keep polling until the pod shows up", func() {
+			c, ec := newTestClient()
+			go func() {
+				defer GinkgoRecover()
+				time.Sleep(5 * testInterval)
+				Expect(ec.Client.Create(ctx, newReadyTestPod(name.Name))).To(Succeed())
+			}()
+			Expect(c.WaitForPodReadyWithInterval(ctx, name, 5*time.Second, testInterval)).To(Succeed())
+		})
+
+		It("should wait for the ready condition, not just the running phase", func() {
+			c, _ := newTestClient(newTestPod(name.Name, corev1.PodRunning))
+			err := c.WaitForPodReadyWithInterval(ctx, name, 5*testInterval, testInterval)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+		})
+
+		It("should return non-NotFound errors right away", func() {
+			c, ec := newTestClient()
+			errInternal := errors.New("internal error")
+			ec.getErr = func(client.ObjectKey) error { return errInternal }
+			start := time.Now()
+			err := c.WaitForPodReadyWithInterval(ctx, name, 5*time.Second, testInterval)
+			Expect(err).To(MatchError(errInternal))
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		})
+
+		It("should return context error on timeout", func() {
+			c, _ := newTestClient(newTestPod(name.Name, corev1.PodPending))
+			err := c.WaitForPodReadyWithInterval(ctx, name, 5*testInterval, testInterval)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+		})
+
+		It("should fall back to the poll interval for a non-positive interval", func() {
+			c, ec := newTestClient(newTestPod(name.Name, corev1.PodPending))
+			c.opts.PollInterval = testInterval
+			var gets int
+			ec.getErr = func(client.ObjectKey) error {
+				gets++
+				return nil
+			}
+			err := c.WaitForPodReadyWithInterval(ctx, name, 5*testInterval, 0)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+			Expect(gets).To(BeNumerically("<=", 5))
+		})
+	})
+
+	Describe("WaitForPodContainerReady", func() {
+		It("should only consider the given container", func() {
+			name := types.NamespacedName{Name: "pod-0", Namespace: testNamespace}
+			pod := newReadyTestPod(name.Name,
+				corev1.ContainerStatus{Name: "ais-node", Ready: true},
+				corev1.ContainerStatus{Name: "sidecar", Ready: false},
+			)
+			pod.Status.Conditions[0].Status = corev1.ConditionFalse
+			c, _ := newTestClient(pod)
+			Expect(c.WaitForPodContainerReady(ctx, name, "ais-node", time.Second)).To(Succeed())
+			err := c.WaitForPodContainerReady(ctx, name, "sidecar", 5*testInterval)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+		})
+	})
+
+	Describe("UpdateStatefulSetImage", func() {
+		name := types.NamespacedName{Name: "ss", Namespace: testNamespace}
+
+		It("should update the image of container at index", func() {
+			c, _ := newTestClient(newTestStatefulSet(name.Name, 1, "ais:old"))
+			updated, err := c.UpdateStatefulSetImage(ctx, name, 0, "ais:new")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			ss, err := c.GetStatefulSet(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss.Spec.Template.Spec.Containers[0].Image).To(Equal("ais:new"))
+		})
+
+		It("should fail for out of range index", func() {
+			c, _ := newTestClient(newTestStatefulSet(name.Name, 1, "ais:old"))
+			updated, err := c.UpdateStatefulSetImage(ctx, name, 3, "ais:new")
+			Expect(err).To(MatchError(ContainSubstring("container index 3 out of range")))
+			Expect(updated).To(BeFalse())
+		})
+
+		It("should update the image of container by name", func() {
+			c, _ := newTestClient(newTestStatefulSet(name.Name, 1, "sidecar", "ais:old"))
+			updated, err := c.UpdateStatefulSetImageByName(ctx, name, "container-1", "ais:new")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			ss, err := c.GetStatefulSet(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss.Spec.Template.Spec.Containers[0].Image).To(Equal("sidecar"))
+			Expect(ss.Spec.Template.Spec.Containers[1].Image).To(Equal("ais:new"))
+		})
+
+		It("should fail when container name is not found", func() {
+			c, _ := newTestClient(newTestStatefulSet(name.Name, 1, "ais:old"))
+			updated, err := c.UpdateStatefulSetImageByName(ctx, name, "missing", "ais:new")
+			Expect(err).To(MatchError(ContainSubstring(`container "missing" not found`)))
+			Expect(updated).To(BeFalse())
+		})
+	})
+
+	Describe("UpdateStatefulSetResources", func() {
+		name := types.NamespacedName{Name: "ss", Namespace: testNamespace}
+		resources := corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+		}
+
+		It("should patch only the resources of container at index", func() {
+			c, ec := newTestClient(newTestStatefulSet(name.Name, 1, "sidecar", "ais:old"))
+			ec.updateErr = func(client.Object) error { return errors.New("unexpected update") }
+			updated, err := c.UpdateStatefulSetResources(ctx, name, 1, resources)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			ss, err := c.GetStatefulSet(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss.Spec.Template.Spec.Containers[0].Resources.Requests).To(BeEmpty())
+			Expect(ss.Spec.Template.Spec.Containers[1].Resources.Requests.Cpu().String()).To(Equal("2"))
+			Expect(ss.Spec.Template.Spec.Containers[1].Image).To(Equal("ais:old"))
+
+			updated, err = c.UpdateStatefulSetResources(ctx, name, 1, resources)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+		})
+	})
+
+	Describe("RecreateStatefulSetPreservingPods", func() {
+		name := types.NamespacedName{Name: "ss", Namespace: testNamespace}
+
+		It("should recreate the statefulset with the new spec, keeping the pods", func() {
+			ss := newTestStatefulSet(name.Name, 1, "ais")
+			ss.Labels = map[string]string{"app": "ais"}
+			ss.Spec.ServiceName = "old-svc"
+			c, _ := newTestClient(ss, newTestPod("ss-0", corev1.PodRunning))
+
+			newSpec := *ss.Spec.DeepCopy()
+			newSpec.ServiceName = "new-svc"
+			Expect(c.RecreateStatefulSetPreservingPods(ctx, name, newSpec)).To(Succeed())
+
+			recreated, err := c.GetStatefulSet(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recreated.Spec.ServiceName).To(Equal("new-svc"))
+			Expect(recreated.Labels).To(HaveKeyWithValue("app", "ais"))
+			_, err = c.GetPodByName(ctx, types.NamespacedName{Name: "ss-0", Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should refuse to change the selector", func() {
+			ss := newTestStatefulSet(name.Name, 1, "ais")
+			c, _ := newTestClient(ss)
+			newSpec := *ss.Spec.DeepCopy()
+			newSpec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "other"}}
+			Expect(c.RecreateStatefulSetPreservingPods(ctx, name, newSpec)).To(MatchError(ContainSubstring("selector must not change")))
+			_, err := c.GetStatefulSet(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("UpdateStatefulSetNodeSelector", func() {
+		It("should update the pod template only when the selector changes", func() {
+			name := types.NamespacedName{Name: "ss", Namespace: testNamespace}
+			c, _ := newTestClient(newTestStatefulSet(name.Name, 1, "ais"))
+			updated, err := c.UpdateStatefulSetNodeSelector(ctx, name, map[string]string{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+
+			selector := map[string]string{"pool": "storage"}
+			updated, err = c.UpdateStatefulSetNodeSelector(ctx, name, selector)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			updated, err = c.UpdateStatefulSetNodeSelector(ctx, name, selector)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+
+			ss, err := c.GetStatefulSet(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss.Spec.Template.Spec.NodeSelector).To(Equal(selector))
+		})
+	})
+
+	Describe("EnsureStatefulSetInitContainer", func() {
+		name := types.NamespacedName{Name: "ss", Namespace: testNamespace}
+
+		It("should add, update and remove the init container", func() {
+			c, _ := newTestClient(newTestStatefulSet(name.Name, 1, "ais"))
+			container := corev1.Container{Name: "prepare-disks", Image: "prep:v1", Command: []string{"/prep.sh"}}
+			updated, err := c.EnsureStatefulSetInitContainer(ctx, name, container)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			updated, err = c.EnsureStatefulSetInitContainer(ctx, name, container)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+
+			container.Image = "prep:v2"
+			updated, err = c.EnsureStatefulSetInitContainer(ctx, name, container)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			ss, err := c.GetStatefulSet(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss.Spec.Template.Spec.InitContainers).To(HaveLen(1))
+			Expect(ss.Spec.Template.Spec.InitContainers[0].Image).To(Equal("prep:v2"))
+
+			updated, err = c.RemoveStatefulSetInitContainer(ctx, name, container.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			updated, err = c.RemoveStatefulSetInitContainer(ctx, name, container.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+		})
+	})
+
+	Describe("WaitForStatefulSetReady", func() {
+		name := types.NamespacedName{Name: "ss", Namespace: testNamespace}
+
+		It("should keep waiting until the statefulset shows up and is ready", func() {
+			c, ec := newTestClient()
+			go func() {
+				defer GinkgoRecover()
+				time.Sleep(5 * testInterval)
+				ss := newTestStatefulSet(name.Name, 2, "ais")
+				ss.Status.ReadyReplicas = 2
+				Expect(ec.Client.Create(ctx, ss)).To(Succeed())
+			}()
+			Expect(c.WaitForStatefulSetReadyWithInterval(ctx, name, 5*time.Second, testInterval)).To(Succeed())
+		})
+
+		It("should report ready replicas on timeout", func() {
+			ss := newTestStatefulSet(name.Name, 3, "ais")
+			ss.Status.ReadyReplicas = 1
+			c, _ := newTestClient(ss)
+			err := c.WaitForStatefulSetReadyWithInterval(ctx, name, 5*testInterval, testInterval)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+			Expect(err).To(MatchError(ContainSubstring("1/3 replicas ready")))
+		})
+
+		It("should fall back to the poll interval for a non-positive interval", func() {
+			c, ec := newTestClient(newTestStatefulSet(name.Name, 3, "ais"))
+			c.opts.PollInterval = testInterval
+			var gets int
+			ec.getErr = func(client.ObjectKey) error {
+				gets++
+				return nil
+			}
+			err := c.WaitForStatefulSetReadyWithInterval(ctx, name, 5*testInterval, -1)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+			Expect(gets).To(BeNumerically("<=", 7))
+		})
+	})
+
+	Describe("typed not found errors", func() {
+		name := types.NamespacedName{Name: "missing", Namespace: testNamespace}
+
+		It("should match both sentinel and API not found errors", func() {
+			c, _ := newTestClient()
+			_, err := c.GetStatefulSet(ctx, name)
+			Expect(errors.Is(err, ErrStatefulSetNotFound)).To(BeTrue())
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+			_, err = c.GetAIStoreCR(ctx, name)
+			Expect(errors.Is(err, ErrAIStoreNotFound)).To(BeTrue())
+			Expect(errors.Is(err, ErrStatefulSetNotFound)).To(BeFalse())
+
+			_, err = c.GetServiceByName(ctx, name)
+			Expect(errors.Is(err, ErrServiceNotFound)).To(BeTrue())
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Describe("UpdateStatefulSetReplicas", func() {
+		name := types.NamespacedName{Name: "ss", Namespace: testNamespace}
+
+		It("should retry the update after a conflict", func() {
+			c, ec := newTestClient(newTestStatefulSet(name.Name, 1, "ais"))
+			conflicts := 0
+			ec.updateErr = func(obj client.Object) error {
+				if conflicts > 0 {
+					return nil
+				}
+				conflicts++
+				return apierrors.NewConflict(apiv1.Resource("statefulsets"), obj.GetName(), errors.New("object modified"))
+			}
+			updated, err := c.UpdateStatefulSetReplicas(ctx, name, 3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			Expect(conflicts).To(Equal(1))
+			ss, err := c.GetStatefulSet(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*ss.Spec.Replicas).To(BeEquivalentTo(3))
+		})
+
+		It("should not update when replicas already match", func() {
+			c, ec := newTestClient(newTestStatefulSet(name.Name, 2, "ais"))
+			ec.updateErr = func(client.Object) error { return errors.New("unexpected update") }
+			updated, err := c.UpdateStatefulSetReplicas(ctx, name, 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+		})
+
+		It("should treat unset replicas as 1", func() {
+			ss := newTestStatefulSet(name.Name, 0, "ais")
+			ss.Spec.Replicas = nil
+			c, _ := newTestClient(ss)
+			previous, updated, err := c.UpdateStatefulSetReplicasDetailed(ctx, name, 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+			Expect(previous).To(BeEquivalentTo(1))
+
+			previous, updated, err = c.UpdateStatefulSetReplicasDetailed(ctx, name, 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			Expect(previous).To(BeEquivalentTo(1))
+		})
+	})
+
+	Describe("WaitForPodDeleted", func() {
+		name := types.NamespacedName{Name: "pod-0", Namespace: testNamespace}
+
+		It("should return once the pod is gone", func() {
+			c, _ := newTestClient()
+			Expect(c.WaitForPodDeleted(ctx, name, time.Second)).To(Succeed())
+		})
+
+		It("should report last observed phase on timeout", func() {
+			c, _ := newTestClient(newTestPod(name.Name, corev1.PodRunning))
+			err := c.WaitForPodDeleted(ctx, name, 5*testInterval)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+			Expect(err).To(MatchError(ContainSubstring(`phase "Running"`)))
+		})
+
+		It("should fall back to the client options when called without a timeout", func() {
+			c, _ := newTestClient(newTestPod(name.Name, corev1.PodRunning))
+			c.opts = ClientOptions{DefaultWaitTimeout: 5 * testInterval, PollInterval: testInterval}
+			Expect(c.WaitForPodDeleted(ctx, name, 0)).To(MatchError(context.DeadlineExceeded))
+		})
+	})
+
+	Describe("CreateOrUpdate", func() {
+		It("should create and then update the resource", func() {
+			owner := &aisv1.AIStore{ObjectMeta: metav1.ObjectMeta{Name: "ais", Namespace: testNamespace, UID: "ais-uid"}}
+			c, _ := newTestClient(owner)
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm"}}
+			value := "v1"
+			mutate := func() error {
+				cm.Data = map[string]string{"key": value}
+				return nil
+			}
+
+			result, err := c.CreateOrUpdate(ctx, owner, cm, mutate)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(controllerutil.OperationResultCreated))
+
+			value = "v2"
+			result, err = c.CreateOrUpdate(ctx, owner, cm, mutate)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(controllerutil.OperationResultUpdated))
+
+			existing, err := c.GetCMByName(ctx, types.NamespacedName{Name: "cm", Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(existing.Data).To(HaveKeyWithValue("key", "v2"))
+			Expect(metav1.IsControlledBy(existing, owner)).To(BeTrue())
+		})
+
+		It("should stamp the spec hash of the desired state", func() {
+			c, _ := newTestClient()
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: testNamespace}}
+			mutate := func() error {
+				cm.Data = map[string]string{"key": "v1"}
+				return nil
+			}
+			_, err := c.CreateOrUpdate(ctx, nil, cm, mutate)
+			Expect(err).NotTo(HaveOccurred())
+
+			desired := &corev1.ConfigMap{Data: map[string]string{"key": "v1"}}
+			existing, err := c.GetCMByName(ctx, types.NamespacedName{Name: "cm", Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(SpecHashMatches(existing, ComputeSpecHash(desired))).To(BeTrue())
+			desired.Data["key"] = "v2"
+			Expect(SpecHashMatches(existing, ComputeSpecHash(desired))).To(BeFalse())
+
+			result, err := c.CreateOrUpdate(ctx, nil, cm, mutate)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(controllerutil.OperationResultNone))
+		})
+
+		It("should not hash the fields defaulted by the server", func() {
+			// The existing service carries `clusterIP` assigned by the server.
+			c, _ := newTestClient(&corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: testNamespace},
+				Spec:       corev1.ServiceSpec{ClusterIP: "10.0.0.1", Type: corev1.ServiceTypeClusterIP},
+			})
+			ports := []corev1.ServicePort{{Name: "http", Port: 8080}}
+			svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: testNamespace}}
+			mutate := func() error {
+				svc.Spec.Ports = ports
+				return nil
+			}
+			_, err := c.CreateOrUpdate(ctx, nil, svc, mutate)
+			Expect(err).NotTo(HaveOccurred())
+
+			existing, err := c.GetServiceByName(ctx, types.NamespacedName{Name: "svc", Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(existing.Spec.ClusterIP).To(Equal("10.0.0.1"))
+			desired := &corev1.Service{Spec: corev1.ServiceSpec{Ports: ports}}
+			Expect(SpecHashMatches(existing, ComputeSpecHash(desired))).To(BeTrue())
+		})
+	})
+
+	Describe("DeleteResourceIfExists", func() {
+		var (
+			ais      *aisv1.AIStore
+			recorder *record.FakeRecorder
+		)
+
+		BeforeEach(func() {
+			ais = &aisv1.AIStore{ObjectMeta: metav1.ObjectMeta{Name: "ais", Namespace: testNamespace, UID: "ais-uid"}}
+			recorder = record.NewFakeRecorder(10)
+		})
+
+		It("should record the deletion on the given owner", func() {
+			c, _ := newTestClient(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: testNamespace}})
+			c.recorder = recorder
+			existed, err := c.DeleteServiceIfExists(ctx, ais, types.NamespacedName{Name: "svc", Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(existed).To(BeTrue())
+			Expect(recorder.Events).To(Receive(Equal(`Normal ResourceDeleted Deleted Service "svc"`)))
+		})
+
+		It("should record the deletion of PVCs not controlled by the owner", func() {
+			labels := map[string]string{"app": "ais"}
+			c, _ := newTestClient(&corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: testNamespace, Labels: labels},
+			})
+			c.recorder = recorder
+			existed, err := c.DeleteAllPVCsIfExist(ctx, ais, testNamespace, labels)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(existed).To(BeTrue())
+			Expect(recorder.Events).To(Receive(Equal(`Normal ResourceDeleted Deleted PersistentVolumeClaim "pvc"`)))
+		})
+
+		It("should fall back to the controlling AIStore", func() {
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: testNamespace}}
+			Expect(controllerutil.SetControllerReference(ais, cm, testScheme)).To(Succeed())
+			c, _ := newTestClient(cm)
+			c.recorder = recorder
+			_, err := c.DeleteResourceIfExists(ctx, nil, cm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(Equal(`Normal ResourceDeleted Deleted ConfigMap "cm"`)))
+		})
+
+		It("should not record anything without an owner", func() {
+			c, _ := newTestClient(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: testNamespace}})
+			c.recorder = recorder
+			_, err := c.DeleteConfigMapIfExists(ctx, nil, types.NamespacedName{Name: "cm", Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("should not record anything if the resource doesn't exist", func() {
+			c, _ := newTestClient()
+			c.recorder = recorder
+			existed, err := c.DeleteServiceIfExists(ctx, ais, types.NamespacedName{Name: "svc", Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(existed).To(BeFalse())
+			Expect(recorder.Events).NotTo(Receive())
+		})
+	})
+
+	Describe("DeleteAllOwnedPVCsIfExist", func() {
+		It("should delete only PVCs owned by the given owner", func() {
+			labels := map[string]string{"app": "ais"}
+			newPVC := func(name string, ownerUID types.UID) *corev1.PersistentVolumeClaim {
+				return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+					Name: name, Namespace: testNamespace, Labels: labels,
+					OwnerReferences: []metav1.OwnerReference{{Name: "owner", UID: ownerUID}},
+				}}
+			}
+			c, _ := newTestClient(newPVC("pvc-mine", "uid-mine"), newPVC("pvc-other", "uid-other"))
+			existed, err := c.DeleteAllOwnedPVCsIfExist(ctx, testNamespace, labels, "uid-mine")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(existed).To(BeTrue())
+
+			pvcs := &corev1.PersistentVolumeClaimList{}
+			Expect(c.List(ctx, pvcs, client.InNamespace(testNamespace))).To(Succeed())
+			Expect(pvcs.Items).To(HaveLen(1))
+			Expect(pvcs.Items[0].Name).To(Equal("pvc-other"))
+		})
+	})
+
+	Describe("DeleteAllPVCsIfExist", func() {
+		It("should warn only about PVCs controlled by another owner", func() {
+			var (
+				lines  []string
+				labels = map[string]string{"app": "ais"}
+				ais    = &aisv1.AIStore{ObjectMeta: metav1.ObjectMeta{Name: "ais", Namespace: testNamespace, UID: "ais-uid"}}
+			)
+			newPVC := func(name string, controllerUID types.UID) *corev1.PersistentVolumeClaim {
+				isController := true
+				return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+					Name: name, Namespace: testNamespace, Labels: labels,
+					OwnerReferences: []metav1.OwnerReference{
+						{Kind: "AIStore", Name: "owner", UID: controllerUID, Controller: &isController},
+					},
+				}}
+			}
+			c, _ := newTestClient(newPVC("pvc-mine", ais.UID), newPVC("pvc-other", "uid-other"))
+			c.opts.Logger = funcr.New(func(_, args string) { lines = append(lines, args) }, funcr.Options{})
+
+			existed, err := c.DeleteAllPVCsIfExist(ctx, ais, testNamespace, labels)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(existed).To(BeTrue())
+			Expect(lines).To(HaveLen(1))
+			Expect(lines[0]).To(ContainSubstring(`"pvc"="pvc-other"`))
+
+			pvcs := &corev1.PersistentVolumeClaimList{}
+			Expect(c.List(ctx, pvcs, client.InNamespace(testNamespace))).To(Succeed())
+			Expect(pvcs.Items).To(BeEmpty())
+		})
+	})
+
+	Describe("DeleteCompletedJobs", func() {
+		It("should delete only jobs finished long enough ago", func() {
+			labels := map[string]string{"app": "ais"}
+			newJob := func(name string, condition batchv1.JobConditionType, finishedAgo time.Duration) *batchv1.Job {
+				job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, Labels: labels}}
+				if condition != "" {
+					finishedAt := metav1.NewTime(time.Now().Add(-finishedAgo))
+					job.Status.Conditions = []batchv1.JobCondition{
+						{Type: condition, Status: corev1.ConditionTrue, LastTransitionTime: finishedAt},
+					}
+				}
+				return job
+			}
+			c, _ := newTestClient(
+				newJob("old-complete", batchv1.JobComplete, 2*time.Hour),
+				newJob("old-failed", batchv1.JobFailed, 2*time.Hour),
+				newJob("recent-complete", batchv1.JobComplete, time.Minute),
+				newJob("running", "", 0),
+			)
+			deleted, err := c.DeleteCompletedJobs(ctx, testNamespace, labels, time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deleted).To(Equal(2))
+
+			jobs, err := c.ListJobs(ctx, testNamespace, labels)
+			Expect(err).NotTo(HaveOccurred())
+			names := make([]string, 0, len(jobs.Items))
+			for i := range jobs.Items {
+				names = append(names, jobs.Items[i].Name)
+			}
+			Expect(names).To(ConsistOf("recent-complete", "running"))
+		})
+	})
+
+	Describe("UpdateConfigMapIfChanged", func() {
+		It("should update only when data differs", func() {
+			existing := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: testNamespace},
+				Data:       map[string]string{"key": "v1"},
+			}
+			c, ec := newTestClient(existing)
+			updates := 0
+			ec.updateErr = func(client.Object) error {
+				updates++
+				return nil
+			}
+
+			cm := existing.DeepCopy()
+			updated, err := c.UpdateConfigMapIfChanged(ctx, cm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+			Expect(updates).To(Equal(0))
+
+			cm.Data["key"] = "v2"
+			updated, err = c.UpdateConfigMapIfChanged(ctx, cm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			Expect(updates).To(Equal(1))
+		})
+	})
+
+	Describe("ResizePVC", func() {
+		name := types.NamespacedName{Name: "pvc", Namespace: testNamespace}
+
+		newPVCAndSC := func(allowExpansion bool) (*corev1.PersistentVolumeClaim, *storagev1.StorageClass) {
+			scName := "sc"
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &scName,
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+					},
+				},
+			}
+			sc := &storagev1.StorageClass{
+				ObjectMeta:           metav1.ObjectMeta{Name: scName},
+				AllowVolumeExpansion: &allowExpansion,
+			}
+			return pvc, sc
+		}
+
+		It("should expand the PVC", func() {
+			c, _ := newTestClient(newPVCAndSC(true))
+			updated, err := c.ResizePVC(ctx, name, resource.MustParse("20Gi"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+		})
+
+		It("should refuse to shrink the PVC", func() {
+			c, _ := newTestClient(newPVCAndSC(true))
+			_, err := c.ResizePVC(ctx, name, resource.MustParse("5Gi"))
+			Expect(err).To(MatchError(ContainSubstring("cannot shrink")))
+		})
+
+		It("should fail if StorageClass doesn't allow expansion", func() {
+			c, _ := newTestClient(newPVCAndSC(false))
+			_, err := c.ResizePVC(ctx, name, resource.MustParse("20Gi"))
+			Expect(err).To(MatchError(ContainSubstring("doesn't allow volume expansion")))
+		})
+	})
+
+	Describe("WaitForPVCsBound", func() {
+		labels := map[string]string{"app": "ais"}
+		newPVC := func(name string, phase corev1.PersistentVolumeClaimPhase) *corev1.PersistentVolumeClaim {
+			return &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, Labels: labels},
+				Status:     corev1.PersistentVolumeClaimStatus{Phase: phase},
+			}
+		}
+
+		It("should return once all PVCs are bound", func() {
+			c, _ := newTestClient(newPVC("pvc-0", corev1.ClaimBound), newPVC("pvc-1", corev1.ClaimBound))
+			Expect(c.WaitForPVCsBound(ctx, testNamespace, labels, time.Second)).To(Succeed())
+		})
+
+		It("should name pending PVCs on timeout", func() {
+			c, _ := newTestClient(newPVC("pvc-0", corev1.ClaimBound), newPVC("pvc-1", corev1.ClaimPending))
+			err := c.WaitForPVCsBound(ctx, testNamespace, labels, 5*testInterval)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+			Expect(err).To(MatchError(ContainSubstring("pvc-1")))
+			Expect(err).NotTo(MatchError(ContainSubstring("pvc-0")))
+		})
+
+		It("should keep waiting until the PVCs show up", func() {
+			c, _ := newTestClient()
+			err := c.WaitForPVCsBound(ctx, testNamespace, labels, 5*testInterval)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+			Expect(err).To(MatchError(ContainSubstring("no PVCs")))
+		})
+	})
+
+	Describe("WaitForLoadBalancer", func() {
+		name := types.NamespacedName{Name: "svc", Namespace: testNamespace}
+		newLBService := func(ingress ...corev1.LoadBalancerIngress) *corev1.Service {
+			return &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace},
+				Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+				Status:     corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: ingress}},
+			}
+		}
+
+		It("should return assigned IP or hostname", func() {
+			c, _ := newTestClient(newLBService(corev1.LoadBalancerIngress{IP: "10.0.0.1"}))
+			Expect(c.WaitForLoadBalancer(ctx, name, time.Second)).To(Equal("10.0.0.1"))
+
+			c, _ = newTestClient(newLBService(corev1.LoadBalancerIngress{Hostname: "lb.example.com"}))
+			Expect(c.WaitForLoadBalancer(ctx, name, time.Second)).To(Equal("lb.example.com"))
+		})
+
+		It("should time out if no ingress is assigned", func() {
+			c, _ := newTestClient(newLBService())
+			_, err := c.WaitForLoadBalancer(ctx, name, 5*testInterval)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+		})
+	})
+
+	Describe("GetByGVK", func() {
+		It("should return typed object for registered kinds", func() {
+			c, _ := newTestClient(newTestPod("pod-0", corev1.PodRunning))
+			obj, err := c.GetByGVK(ctx, types.NamespacedName{Name: "pod-0", Namespace: testNamespace},
+				corev1.SchemeGroupVersion.WithKind("Pod"))
+			Expect(err).NotTo(HaveOccurred())
+			pod, ok := obj.(*corev1.Pod)
+			Expect(ok).To(BeTrue())
+			Expect(pod.Status.Phase).To(Equal(corev1.PodRunning))
+		})
+	})
+
+	Describe("StatefulSetRolloutComplete", func() {
+		name := types.NamespacedName{Name: "ss", Namespace: testNamespace}
+
+		It("should check replicas and revisions", func() {
+			ss := newTestStatefulSet(name.Name, 2, "ais")
+			ss.Status = apiv1.StatefulSetStatus{
+				ReadyReplicas:   2,
+				UpdatedReplicas: 2,
+				CurrentRevision: "rev-1",
+				UpdateRevision:  "rev-2",
+			}
+			c, ec := newTestClient(ss)
+			done, err := c.StatefulSetRolloutComplete(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(done).To(BeFalse())
+
+			ss.Status.CurrentRevision = "rev-2"
+			Expect(ec.Client.Update(ctx, ss)).To(Succeed())
+			done, err = c.StatefulSetRolloutComplete(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(done).To(BeTrue())
+		})
+	})
+
+	Describe("SetStatefulSetUpdatePartition", func() {
+		It("should patch the rolling update partition", func() {
+			name := types.NamespacedName{Name: "ss", Namespace: testNamespace}
+			c, _ := newTestClient(newTestStatefulSet(name.Name, 3, "ais"))
+			Expect(c.SetStatefulSetUpdatePartition(ctx, name, 2)).To(Succeed())
+			ss, err := c.GetStatefulSet(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss.Spec.UpdateStrategy.RollingUpdate).NotTo(BeNil())
+			Expect(*ss.Spec.UpdateStrategy.RollingUpdate.Partition).To(BeEquivalentTo(2))
+			Expect(ss.Spec.Template.Spec.Containers[0].Image).To(Equal("ais"))
+		})
+	})
+
+	Describe("AddFinalizer and RemoveFinalizer", func() {
+		const finalizer = "finalize.ais"
+
+		It("should patch finalizers only when they change", func() {
+			ais := &aisv1.AIStore{ObjectMeta: metav1.ObjectMeta{Name: "ais", Namespace: testNamespace}}
+			c, ec := newTestClient(ais.DeepCopy())
+			Expect(ec.Client.Get(ctx, client.ObjectKeyFromObject(ais), ais)).To(Succeed())
+
+			updated, err := c.AddFinalizer(ctx, ais, finalizer)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			updated, err = c.AddFinalizer(ctx, ais, finalizer)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+
+			stored := &aisv1.AIStore{}
+			Expect(ec.Client.Get(ctx, client.ObjectKeyFromObject(ais), stored)).To(Succeed())
+			Expect(stored.Finalizers).To(ConsistOf(finalizer))
+
+			updated, err = c.RemoveFinalizer(ctx, ais, finalizer)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			updated, err = c.RemoveFinalizer(ctx, ais, finalizer)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+
+			Expect(ec.Client.Get(ctx, client.ObjectKeyFromObject(ais), stored)).To(Succeed())
+			Expect(stored.Finalizers).To(BeEmpty())
+		})
+	})
+
+	Describe("CreateNamespaceIfNotExists", func() {
+		It("should create the namespace once", func() {
+			c, _ := newTestClient()
+			exists, err := c.CreateNamespaceIfNotExists(ctx, "ais-ns", map[string]string{"app": "ais"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeFalse())
+
+			exists, err = c.CreateNamespaceIfNotExists(ctx, "ais-ns", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+
+			exists, err = c.CheckIfNamespaceExists(ctx, "ais-ns")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+		})
+	})
+
+	Describe("CreateRoleBindingIfNotExists", func() {
+		It("should set the controller reference", func() {
+			ais := &aisv1.AIStore{ObjectMeta: metav1.ObjectMeta{Name: "ais", Namespace: testNamespace, UID: "ais-uid"}}
+			c, _ := newTestClient()
+			rb := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "ais-rb"}}
+			exists, err := c.CreateRoleBindingIfNotExists(ctx, ais, rb)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeFalse())
+
+			stored := &rbacv1.RoleBinding{}
+			Expect(c.client.Get(ctx, types.NamespacedName{Name: "ais-rb", Namespace: testNamespace}, stored)).To(Succeed())
+			Expect(metav1.IsControlledBy(stored, ais)).To(BeTrue())
+		})
+	})
+
+	Describe("CreateHeadlessServiceIfNotExists", func() {
+		It("should create a headless service selecting the pods", func() {
+			c, _ := newTestClient()
+			name := types.NamespacedName{Name: "ais-headless", Namespace: testNamespace}
+			selector := map[string]string{"app": "ais"}
+			ports := []corev1.ServicePort{{Name: "pub", Port: 51080}}
+			exists, err := c.CreateHeadlessServiceIfNotExists(ctx, nil, name, selector, ports)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeFalse())
+
+			svc, err := c.GetServiceByName(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(svc.Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+			Expect(svc.Spec.Selector).To(Equal(selector))
+			Expect(svc.Spec.Ports).To(HaveLen(1))
+
+			exists, err = c.CreateHeadlessServiceIfNotExists(ctx, nil, name, selector, ports)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+		})
+	})
+
+	Describe("ReconcileServiceType", func() {
+		name := types.NamespacedName{Name: "proxy", Namespace: testNamespace}
+
+		It("should clear the fields not valid for the new type", func() {
+			lbClass := "cloud-lb"
+			svc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace},
+				Spec: corev1.ServiceSpec{
+					Type:              corev1.ServiceTypeLoadBalancer,
+					ClusterIP:         "10.0.0.1",
+					LoadBalancerClass: &lbClass,
+					Ports:             []corev1.ServicePort{{Name: "pub", Port: 51080, NodePort: 30080}},
+				},
+			}
+			c, _ := newTestClient(svc)
+			updated, err := c.ReconcileServiceType(ctx, name, corev1.ServiceTypeClusterIP)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			updated, err = c.ReconcileServiceType(ctx, name, corev1.ServiceTypeClusterIP)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+
+			svc, err = c.GetServiceByName(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(svc.Spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
+			Expect(svc.Spec.ClusterIP).To(Equal("10.0.0.1"))
+			Expect(svc.Spec.LoadBalancerClass).To(BeNil())
+			Expect(svc.Spec.Ports[0].NodePort).To(BeZero())
+		})
+
+		It("should refuse to expose a headless service", func() {
+			c, _ := newTestClient(&corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace},
+				Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP, ClusterIP: corev1.ClusterIPNone},
+			})
+			_, err := c.ReconcileServiceType(ctx, name, corev1.ServiceTypeLoadBalancer)
+			Expect(err).To(MatchError(ContainSubstring("headless service")))
+		})
+	})
+
+	Describe("UpdateServicePorts", func() {
+		name := types.NamespacedName{Name: "ais-lb", Namespace: testNamespace}
+
+		It("should update the ports preserving the allocated node ports", func() {
+			svc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace},
+				Spec: corev1.ServiceSpec{
+					Type:      corev1.ServiceTypeNodePort,
+					ClusterIP: "10.0.0.1",
+					Ports: []corev1.ServicePort{{
+						Name: "pub", Protocol: corev1.ProtocolTCP, Port: 51080,
+						TargetPort: intstr.FromInt(51080), NodePort: 30080,
+					}},
+				},
+			}
+			c, _ := newTestClient(svc)
+
+			updated, err := c.UpdateServicePorts(ctx, name, []corev1.ServicePort{{Name: "pub", Port: 51080}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+
+			updated, err = c.UpdateServicePorts(ctx, name, []corev1.ServicePort{{Name: "pub", Port: 52080}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+
+			stored, err := c.GetServiceByName(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored.Spec.ClusterIP).To(Equal("10.0.0.1"))
+			Expect(stored.Spec.Ports).To(HaveLen(1))
+			Expect(stored.Spec.Ports[0].Port).To(BeEquivalentTo(52080))
+			Expect(stored.Spec.Ports[0].NodePort).To(BeEquivalentTo(30080))
+		})
+	})
+
+	Describe("UpdateResourceWithRetry", func() {
+		It("should update the latest state and skip the update without changes", func() {
+			c, ec := newTestClient(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: testNamespace}})
+			conflicts := 1
+			ec.updateErr = func(client.Object) error {
+				if conflicts > 0 {
+					conflicts--
+					return apierrors.NewConflict(corev1.Resource("services"), "svc", errors.New("modified"))
+				}
+				return nil
+			}
+			svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: testNamespace}}
+			mutate := func() bool {
+				if svc.Labels["team"] == "storage" {
+					return false
+				}
+				metav1.SetMetaDataLabel(&svc.ObjectMeta, "team", "storage")
+				return true
+			}
+			updated, err := c.UpdateResourceWithRetry(ctx, svc, mutate)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			updated, err = c.UpdateResourceWithRetry(ctx, svc, mutate)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+
+			stored, err := c.GetServiceByName(ctx, types.NamespacedName{Name: "svc", Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored.Labels).To(HaveKeyWithValue("team", "storage"))
+		})
+	})
+
+	Describe("RestartStatefulSetOnSecretChange", func() {
+		It("should restart the pods only when the secret changes", func() {
+			name := types.NamespacedName{Name: "ss", Namespace: testNamespace}
+			secretName := types.NamespacedName{Name: "ais-tls", Namespace: testNamespace}
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName.Name, Namespace: secretName.Namespace}}
+			c, ec := newTestClient(newTestStatefulSet(name.Name, 1, "ais"), secret)
+
+			// The first call only records the version.
+			restarted, err := c.RestartStatefulSetOnSecretChange(ctx, name, secretName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restarted).To(BeFalse())
+			ss, err := c.GetStatefulSet(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss.Annotations).To(HaveKey(AnnotationTLSSecretVersion))
+			Expect(ss.Spec.Template.Annotations).NotTo(HaveKey(AnnotationTLSSecretVersion))
+
+			restarted, err = c.RestartStatefulSetOnSecretChange(ctx, name, secretName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restarted).To(BeFalse())
+
+			Expect(ec.Client.Get(ctx, secretName, secret)).To(Succeed())
+			secret.Data = map[string][]byte{"tls.crt": []byte("rotated")}
+			Expect(ec.Client.Update(ctx, secret)).To(Succeed())
+			restarted, err = c.RestartStatefulSetOnSecretChange(ctx, name, secretName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restarted).To(BeTrue())
+
+			ss, err = c.GetStatefulSet(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ss.Annotations).To(HaveKeyWithValue(AnnotationTLSSecretVersion, secret.ResourceVersion))
+			Expect(ss.Spec.Template.Annotations).To(HaveKeyWithValue(AnnotationTLSSecretVersion, secret.ResourceVersion))
+		})
+	})
+
+	Describe("ListOrphanedPods", func() {
+		It("should list pods without a known statefulset controller", func() {
+			ss := newTestStatefulSet("ss", 2, "ais")
+			ss.UID = "ss-uid"
+			labels := map[string]string{"app": "ais"}
+			newPod := func(name string, owner types.UID) *corev1.Pod {
+				pod := newTestPod(name, corev1.PodRunning)
+				pod.Labels = labels
+				if owner != "" {
+					controller := true
+					pod.OwnerReferences = []metav1.OwnerReference{
+						{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "ss", UID: owner, Controller: &controller},
+					}
+				}
+				return pod
+			}
+			c, _ := newTestClient(ss, newPod("owned", "ss-uid"), newPod("stale-owner", "old-uid"), newPod("no-owner", ""))
+
+			pods, err := c.ListOrphanedPods(ctx, testNamespace, labels)
+			Expect(err).NotTo(HaveOccurred())
+			names := make([]string, 0, len(pods))
+			for _, pod := range pods {
+				names = append(names, pod.Name)
+			}
+			Expect(names).To(ConsistOf("stale-owner", "no-owner"))
+		})
+	})
+
+	Describe("GetStatefulSetLive", func() {
+		It("should read from the API server rather than the cache", func() {
+			cached := newTestStatefulSet("ss", 3, "ais")
+			c, _ := newTestClient(cached)
+			c.apiReader = fake.NewClientBuilder().WithScheme(testScheme).WithObjects(newTestStatefulSet("ss", 1, "ais")).Build()
+			name := types.NamespacedName{Name: "ss", Namespace: testNamespace}
+
+			ss, err := c.GetStatefulSet(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*ss.Spec.Replicas).To(BeEquivalentTo(3))
+			ss, err = c.GetStatefulSetLive(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*ss.Spec.Replicas).To(BeEquivalentTo(1))
+		})
+
+		It("should fall back to the client without an API reader", func() {
+			c, _ := newTestClient()
+			_, err := c.GetStatefulSetLive(ctx, types.NamespacedName{Name: "ss", Namespace: testNamespace})
+			Expect(errors.Is(err, ErrStatefulSetNotFound)).To(BeTrue())
+		})
+	})
+
+	Describe("GetAIStoreForValidation", func() {
+		It("should read the CR from the API server", func() {
+			c, _ := newTestClient()
+			name := types.NamespacedName{Name: "ais", Namespace: testNamespace}
+			_, err := c.GetAIStoreForValidation(ctx, name)
+			Expect(errors.Is(err, ErrAIStoreNotFound)).To(BeTrue())
+
+			c.apiReader = fake.NewClientBuilder().WithScheme(testScheme).WithObjects(
+				&aisv1.AIStore{ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace}}).Build()
+			ais, err := c.GetAIStoreForValidation(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ais.Name).To(Equal(name.Name))
+		})
+	})
+
+	Describe("GetStatefulSetPods", func() {
+		It("should list controlled pods sorted by ordinal", func() {
+			ss := newTestStatefulSet("ss", 3, "ais")
+			ss.UID = "ss-uid"
+			ss.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "ais"}}
+			controller := true
+			objs := []client.Object{ss}
+			for _, name := range []string{"ss-10", "ss-2", "ss-0"} {
+				pod := newTestPod(name, corev1.PodRunning)
+				pod.Labels = map[string]string{"app": "ais"}
+				pod.OwnerReferences = []metav1.OwnerReference{
+					{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "ss", UID: ss.UID, Controller: &controller},
+				}
+				objs = append(objs, pod)
+			}
+			other := newTestPod("other-0", corev1.PodRunning)
+			other.Labels = map[string]string{"app": "ais"}
+			objs = append(objs, other)
+			c, _ := newTestClient(objs...)
+
+			pods, err := c.GetStatefulSetPods(ctx, types.NamespacedName{Name: "ss", Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pods).To(HaveLen(3))
+			Expect(pods[0].Name).To(Equal("ss-0"))
+			Expect(pods[1].Name).To(Equal("ss-2"))
+			Expect(pods[2].Name).To(Equal("ss-10"))
+		})
+	})
+
+	Describe("dry run", func() {
+		It("should not persist creates and updates", func() {
+			name := types.NamespacedName{Name: "ss", Namespace: testNamespace}
+			c, _ := newTestClient(newTestStatefulSet(name.Name, 1, "ais"))
+
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: testNamespace}}
+			exists, err := c.CreateResourceIfNotExists(ctx, nil, cm, client.DryRunAll)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeFalse())
+			_, err = c.GetCMByName(ctx, types.NamespacedName{Name: "cm", Namespace: testNamespace})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+			updated, err := c.UpdateStatefulSetReplicas(ctx, name, 3, client.DryRunAll)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			ss, err := c.GetStatefulSet(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*ss.Spec.Replicas).To(BeEquivalentTo(1))
+		})
+	})
+
+	Describe("SetPodsAnnotation", func() {
+		It("should annotate only pods with a different value", func() {
+			labels := map[string]string{"app": "ais"}
+			stale := newTestPod("pod-0", corev1.PodRunning)
+			stale.Labels = labels
+			current := newTestPod("pod-1", corev1.PodRunning)
+			current.Labels = labels
+			current.Annotations = map[string]string{"ais.nvidia.com/config-hash": "abc"}
+			c, _ := newTestClient(stale, current)
+
+			updated, err := c.SetPodsAnnotation(ctx, testNamespace, labels, "ais.nvidia.com/config-hash", "abc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(Equal(1))
+
+			pod, err := c.GetPodByName(ctx, types.NamespacedName{Name: "pod-0", Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pod.Annotations).To(HaveKeyWithValue("ais.nvidia.com/config-hash", "abc"))
+		})
+	})
+
+	Describe("PatchPodsByLabel", func() {
+		It("should patch matching pods and report per-pod errors", func() {
+			labels := map[string]string{"app": "ais"}
+			pods := []client.Object{}
+			for _, name := range []string{"pod-0", "pod-1"} {
+				pod := newTestPod(name, corev1.PodRunning)
+				pod.Labels = labels
+				pods = append(pods, pod)
+			}
+			other := newTestPod("other", corev1.PodRunning)
+			c, _ := newTestClient(append(pods, other)...)
+
+			podErrs, err := c.PatchPodsByLabel(ctx, testNamespace, labels, []byte(`{"metadata":{"labels":{"version":"v2"}}}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(podErrs).To(BeEmpty())
+			for _, name := range []string{"pod-0", "pod-1", "other"} {
+				pod, err := c.GetPodByName(ctx, types.NamespacedName{Name: name, Namespace: testNamespace})
+				Expect(err).NotTo(HaveOccurred())
+				if name == "other" {
+					Expect(pod.Labels).NotTo(HaveKey("version"))
+				} else {
+					Expect(pod.Labels).To(HaveKeyWithValue("version", "v2"))
+				}
+			}
+
+			podErrs, err = c.PatchPodsByLabel(ctx, testNamespace, labels, []byte(`not json`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(podErrs).To(HaveLen(2))
+			Expect(podErrs).To(HaveKey("pod-0"))
+		})
+	})
+
+	Describe("IsReconcilePaused", func() {
+		It("should only be paused by a true annotation", func() {
+			ais := &aisv1.AIStore{}
+			Expect(IsReconcilePaused(ais)).To(BeFalse())
+			ais.Annotations = map[string]string{AnnotationPaused: "false"}
+			Expect(IsReconcilePaused(ais)).To(BeFalse())
+			ais.Annotations[AnnotationPaused] = "true"
+			Expect(IsReconcilePaused(ais)).To(BeTrue())
+		})
+	})
+
+	Describe("AcquireTopologyLock", func() {
+		var (
+			c   *K8sClient
+			ais *aisv1.AIStore
+		)
+
+		BeforeEach(func() {
+			var ec *errClient
+			c, ec = newTestClient(&aisv1.AIStore{ObjectMeta: metav1.ObjectMeta{Name: "ais", Namespace: testNamespace}})
+			ais = &aisv1.AIStore{}
+			Expect(ec.Client.Get(ctx, types.NamespacedName{Name: "ais", Namespace: testNamespace}, ais)).To(Succeed())
+		})
+
+		It("should serialize operations", func() {
+			acquired, err := c.AcquireTopologyLock(ctx, ais, "scale")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(acquired).To(BeTrue())
+
+			acquired, err = c.AcquireTopologyLock(ctx, ais, "upgrade")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(acquired).To(BeFalse())
+
+			Expect(c.ReleaseTopologyLock(ctx, ais, "scale")).To(Succeed())
+			acquired, err = c.AcquireTopologyLock(ctx, ais, "upgrade")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(acquired).To(BeTrue())
+		})
+
+		It("should take over an expired lock", func() {
+			ais.Annotations = map[string]string{
+				AnnotationTopologyLock:     "scale",
+				AnnotationTopologyLockTime: time.Now().Add(-2 * TopologyLockTTL).UTC().Format(time.RFC3339),
+			}
+			acquired, err := c.AcquireTopologyLock(ctx, ais, "upgrade")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(acquired).To(BeTrue())
+			Expect(ais.Annotations).To(HaveKeyWithValue(AnnotationTopologyLock, "upgrade"))
+		})
+
+		It("should conflict with a stale object", func() {
+			stale := ais.DeepCopy()
+			acquired, err := c.AcquireTopologyLock(ctx, ais, "scale")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(acquired).To(BeTrue())
+
+			_, err = c.AcquireTopologyLock(ctx, stale, "upgrade")
+			Expect(apierrors.IsConflict(err)).To(BeTrue())
+		})
+	})
+
+	Describe("DeletePVCsForOrdinalsAbove", func() {
+		name := types.NamespacedName{Name: "target", Namespace: testNamespace}
+		newPVC := func(name string) *corev1.PersistentVolumeClaim {
+			return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace}}
+		}
+		newSS := func(replicas int32) *apiv1.StatefulSet {
+			ss := newTestStatefulSet(name.Name, replicas, "ais")
+			ss.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}}
+			return ss
+		}
+
+		It("should delete PVCs of removed ordinals only", func() {
+			c, _ := newTestClient(newSS(2),
+				newPVC("data-target-0"), newPVC("data-target-1"), newPVC("data-target-2"), newPVC("data-target-10"),
+				newPVC("data-other-3"))
+			existed, err := c.DeletePVCsForOrdinalsAbove(ctx, nil, name, 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(existed).To(BeTrue())
+
+			for pvcName, exists := range map[string]bool{
+				"data-target-0": true, "data-target-1": true, "data-target-2": false, "data-target-10": false,
+				"data-other-3": true,
+			} {
+				found, err := c.PVCExists(ctx, types.NamespacedName{Name: pvcName, Namespace: testNamespace})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(Equal(exists), pvcName)
+			}
+		})
+
+		It("should refuse to delete PVCs before the statefulset is scaled down", func() {
+			c, _ := newTestClient(newSS(3), newPVC("data-target-2"))
+			_, err := c.DeletePVCsForOrdinalsAbove(ctx, nil, name, 2)
+			Expect(err).To(HaveOccurred())
+			found, err := c.PVCExists(ctx, types.NamespacedName{Name: "data-target-2", Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+		})
+	})
+
+	Describe("GetPVCsForPod", func() {
+		It("should return the PVCs of all volume claim templates", func() {
+			ss := newTestStatefulSet("target", 2, "ais")
+			ss.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{
+				{ObjectMeta: metav1.ObjectMeta{Name: "mpath-0"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "mpath-1"}},
+			}
+			objs := []client.Object{ss}
+			for _, name := range []string{"mpath-0-target-1", "mpath-1-target-1", "mpath-0-target-0"} {
+				objs = append(objs, &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace}})
+			}
+			c, _ := newTestClient(objs...)
+
+			pvcs, err := c.GetPVCsForPod(ctx, types.NamespacedName{Name: "target-1", Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pvcs).To(HaveLen(2))
+			Expect(pvcs[0].Name).To(Equal("mpath-0-target-1"))
+			Expect(pvcs[1].Name).To(Equal("mpath-1-target-1"))
+
+			_, err = c.GetPVCsForPod(ctx, types.NamespacedName{Name: "target", Namespace: testNamespace})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("CheckStorageClassExists", func() {
+		It("should report existence and volume expansion support", func() {
+			allow := true
+			c, _ := newTestClient(&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "fast"}, AllowVolumeExpansion: &allow})
+			exists, expandable, err := c.CheckStorageClassExists(ctx, "fast")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+			Expect(expandable).To(BeTrue())
+
+			exists, expandable, err = c.CheckStorageClassExists(ctx, "missing")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeFalse())
+			Expect(expandable).To(BeFalse())
+		})
+	})
+
+	Describe("EnsureImagePullSecret", func() {
+		It("should create and update the docker config secret", func() {
+			ais := &aisv1.AIStore{ObjectMeta: metav1.ObjectMeta{Name: "ais", Namespace: testNamespace, UID: "ais-uid"}}
+			c, _ := newTestClient()
+			_, err := c.EnsureImagePullSecret(ctx, ais, []byte(`{"auths":{}}`))
+			Expect(err).NotTo(HaveOccurred())
+			name, err := c.EnsureImagePullSecret(ctx, ais, []byte(`{"auths":{"registry":{}}}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			secret, err := c.GetSecretByName(ctx, types.NamespacedName{Name: name, Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secret.Type).To(Equal(corev1.SecretTypeDockerConfigJson))
+			Expect(secret.Data[corev1.DockerConfigJsonKey]).To(Equal([]byte(`{"auths":{"registry":{}}}`)))
+			Expect(metav1.IsControlledBy(secret, ais)).To(BeTrue())
+		})
+	})
+
+	Describe("ListNodes", func() {
+		It("should list nodes matching the labels", func() {
+			newNode := func(name, zone string) *corev1.Node {
+				return &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+					Name:   name,
+					Labels: map[string]string{corev1.LabelTopologyZone: zone, "ais": "true"},
+				}}
+			}
+			c, _ := newTestClient(newNode("node-a", "zone-a"), newNode("node-b", "zone-b"))
+			nodes, err := c.ListNodes(ctx, client.MatchingLabels{corev1.LabelTopologyZone: "zone-b"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nodes.Items).To(HaveLen(1))
+			Expect(nodes.Items[0].Name).To(Equal("node-b"))
+
+			node, err := c.GetNode(ctx, "node-a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(node.Labels).To(HaveKeyWithValue(corev1.LabelTopologyZone, "zone-a"))
+		})
+	})
+
+	Describe("CheckSchedulability", func() {
+		It("should count only schedulable nodes", func() {
+			labels := map[string]string{"ais": "true"}
+			newNode := func(name string, ready bool) *corev1.Node {
+				status := corev1.ConditionFalse
+				if ready {
+					status = corev1.ConditionTrue
+				}
+				return &corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+					Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}}},
+				}
+			}
+			cordoned := newNode("cordoned", true)
+			cordoned.Spec.Unschedulable = true
+			tainted := newNode("tainted", true)
+			tainted.Spec.Taints = []corev1.Taint{{Key: "dedicated", Effect: corev1.TaintEffectNoSchedule}}
+			c, _ := newTestClient(newNode("node-a", true), newNode("node-b", true), newNode("not-ready", false), cordoned, tainted)
+
+			Expect(c.CheckSchedulability(ctx, 2, labels)).To(Succeed())
+			err := c.CheckSchedulability(ctx, 3, labels)
+			Expect(errors.Is(err, ErrNotEnoughNodes)).To(BeTrue())
+		})
+	})
+
+	Describe("MatchPVCsToOrdinals", func() {
+		It("should report PVCs bound to PVs claiming another ordinal", func() {
+			ss := newTestStatefulSet("target", 3, "ais")
+			ss.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}}
+			newPVC := func(name, volume string) *corev1.PersistentVolumeClaim {
+				return &corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+					Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: volume},
+				}
+			}
+			newPV := func(name, claim string) *corev1.PersistentVolume {
+				return &corev1.PersistentVolume{
+					ObjectMeta: metav1.ObjectMeta{Name: name},
+					Spec:       corev1.PersistentVolumeSpec{ClaimRef: &corev1.ObjectReference{Name: claim, Namespace: testNamespace}},
+				}
+			}
+			c, _ := newTestClient(ss,
+				newPVC("data-target-0", "pv-0"), newPV("pv-0", "data-target-0"),
+				newPVC("data-target-1", "pv-2"), newPV("pv-2", "data-target-2"),
+				newPVC("data-target-2", ""),
+			)
+			mismatches, err := c.MatchPVCsToOrdinals(ctx, types.NamespacedName{Name: "target", Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mismatches).To(Equal([]PVCMismatch{
+				{PVC: "data-target-1", PV: "pv-2", ClaimedBy: testNamespace + "/data-target-2"},
+			}))
+		})
+	})
+
+	Describe("CheckPodPVCLocality", func() {
+		It("should compare the pod node to the local PV node affinity", func() {
+			hostname := "kubernetes.io/hostname"
+			newNode := func(name string) *corev1.Node {
+				return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{hostname: name}}}
+			}
+			pv := &corev1.PersistentVolume{
+				ObjectMeta: metav1.ObjectMeta{Name: "local-pv"},
+				Spec: corev1.PersistentVolumeSpec{NodeAffinity: &corev1.VolumeNodeAffinity{
+					Required: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+						MatchExpressions: []corev1.NodeSelectorRequirement{
+							{Key: hostname, Operator: corev1.NodeSelectorOpIn, Values: []string{"node-a"}},
+						},
+					}}},
+				}},
+			}
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "data-target-0", Namespace: testNamespace},
+				Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: pv.Name},
+			}
+			pod := newTestPod("target-0", corev1.PodRunning)
+			pod.Spec.NodeName = "node-b"
+			pod.Spec.Volumes = []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.Name},
+			}}}
+			c, ec := newTestClient(newNode("node-a"), newNode("node-b"), pv, pvc, pod)
+			name := types.NamespacedName{Name: pod.Name, Namespace: testNamespace}
+
+			local, err := c.CheckPodPVCLocality(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(local).To(BeFalse())
+
+			pod.Spec.NodeName = "node-a"
+			Expect(ec.Client.Update(ctx, pod)).To(Succeed())
+			local, err = c.CheckPodPVCLocality(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(local).To(BeTrue())
+		})
+	})
+
+	Describe("CordonNode", func() {
+		It("should patch the node only if schedulability changes", func() {
+			c, _ := newTestClient(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}})
+			updated, err := c.CordonNode(ctx, "node-a", true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			updated, err = c.CordonNode(ctx, "node-a", true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+
+			node, err := c.GetNode(ctx, "node-a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(node.Spec.Unschedulable).To(BeTrue())
+		})
+	})
+
+	Describe("GetHighRestartPods", func() {
+		It("should report pods with containers over the threshold", func() {
+			labels := map[string]string{"app": "ais"}
+			stable := newReadyTestPod("target-0", corev1.ContainerStatus{Name: "ais-node", RestartCount: 1})
+			stable.Labels = labels
+			crashing := newReadyTestPod("target-1",
+				corev1.ContainerStatus{Name: "ais-node", RestartCount: 0},
+				corev1.ContainerStatus{Name: "sidecar", RestartCount: 7},
+			)
+			crashing.Labels = labels
+			c, _ := newTestClient(stable, crashing)
+
+			pods, err := c.GetHighRestartPods(ctx, testNamespace, labels, 5)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pods).To(HaveLen(1))
+			Expect(pods[0].Name).To(Equal("target-1"))
+
+			count, err := c.GetPodRestartCount(ctx, types.NamespacedName{Name: "target-1", Namespace: testNamespace}, "sidecar")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(BeEquivalentTo(7))
+		})
+	})
+
+	Describe("WaitForServiceEndpoints", func() {
+		name := types.NamespacedName{Name: "proxy", Namespace: testNamespace}
+		newEndpoints := func(ready, notReady int) *corev1.Endpoints {
+			subset := corev1.EndpointSubset{}
+			for i := 0; i < ready; i++ {
+				subset.Addresses = append(subset.Addresses, corev1.EndpointAddress{IP: fmt.Sprintf("10.0.0.%d", i)})
+			}
+			for i := 0; i < notReady; i++ {
+				subset.NotReadyAddresses = append(subset.NotReadyAddresses, corev1.EndpointAddress{IP: fmt.Sprintf("10.0.1.%d", i)})
+			}
+			return &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace},
+				Subsets:    []corev1.EndpointSubset{subset},
+			}
+		}
+
+		It("should succeed with enough ready endpoints", func() {
+			c, _ := newTestClient(newEndpoints(2, 1))
+			Expect(c.waitForServiceEndpoints(ctx, name, 2, time.Second, testInterval)).To(Succeed())
+		})
+
+		It("should not count not ready endpoints", func() {
+			c, _ := newTestClient(newEndpoints(1, 2))
+			err := c.waitForServiceEndpoints(ctx, name, 2, 5*testInterval, testInterval)
+			Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		})
+	})
+
+	Describe("GetReadyTargetEndpoints", func() {
+		It("should return the ready addresses across slices of the service", func() {
+			notReady := false
+			newSlice := func(name, service string, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+				return &discoveryv1.EndpointSlice{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: name, Namespace: testNamespace,
+						Labels: map[string]string{discoveryv1.LabelServiceName: service},
+					},
+					AddressType: discoveryv1.AddressTypeIPv4,
+					Endpoints:   endpoints,
+				}
+			}
+			c, _ := newTestClient(
+				newSlice("target-a", "target", discoveryv1.Endpoint{Addresses: []string{"10.0.0.2"}},
+					discoveryv1.Endpoint{Addresses: []string{"10.0.0.3"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}}),
+				newSlice("target-b", "target", discoveryv1.Endpoint{Addresses: []string{"10.0.0.1"}},
+					discoveryv1.Endpoint{Addresses: []string{"10.0.0.2"}}),
+				newSlice("proxy-a", "proxy", discoveryv1.Endpoint{Addresses: []string{"10.0.1.1"}}),
+			)
+			addresses, err := c.GetReadyTargetEndpoints(ctx, types.NamespacedName{Name: "target", Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(addresses).To(Equal([]string{"10.0.0.1", "10.0.0.2"}))
+		})
+	})
+
+	Describe("ListAllAIStoreCR", func() {
+		It("should list CRs across namespaces", func() {
+			c, _ := newTestClient(
+				&aisv1.AIStore{ObjectMeta: metav1.ObjectMeta{Name: "ais", Namespace: "ns-a"}},
+				&aisv1.AIStore{ObjectMeta: metav1.ObjectMeta{Name: "ais", Namespace: "ns-b"}},
+			)
+			list, err := c.ListAllAIStoreCR(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list.Items).To(HaveLen(2))
+		})
+	})
+
+	Describe("CreateResourceWithOwner", func() {
+		It("should set a non-controlling owner reference", func() {
+			ais := &aisv1.AIStore{ObjectMeta: metav1.ObjectMeta{Name: "ais", Namespace: testNamespace, UID: "ais-uid"}}
+			c, _ := newTestClient()
+			svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "metrics"}}
+			exists, err := c.CreateResourceWithOwner(ctx, ais, svc, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeFalse())
+
+			stored, err := c.GetServiceByName(ctx, types.NamespacedName{Name: "metrics", Namespace: testNamespace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored.OwnerReferences).To(HaveLen(1))
+			Expect(stored.OwnerReferences[0].UID).To(Equal(ais.UID))
+			Expect(metav1.GetControllerOf(stored)).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,68 @@
+// Package client contains wrapper for k8s client
+/*
+ * Copyright (c) 2021, NVIDIA CORPORATION. All rights reserved.
+ */
+package client
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	aisv1 "github.com/ais-operator/api/v1beta1"
+)
+
+// These tests use Ginkgo (BDD-style Go testing framework). Refer to
+// http://onsi.github.io/ginkgo/ to learn more about Ginkgo.
+
+var testScheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(testScheme))
+	utilruntime.Must(aisv1.AddToScheme(testScheme))
+}
+
+func TestClient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Client Suite")
+}
+
+// errClient wraps a fake client, allowing tests to inject errors into `Get` and `Update` calls.
+type errClient struct {
+	client.Client
+	getErr    func(key client.ObjectKey) error
+	updateErr func(obj client.Object) error
+}
+
+func (c *errClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if c.getErr != nil {
+		if err := c.getErr(key); err != nil {
+			return err
+		}
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *errClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if c.updateErr != nil {
+		if err := c.updateErr(obj); err != nil {
+			return err
+		}
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func newTestClient(objs ...client.Object) (*K8sClient, *errClient) {
+	ec := &errClient{
+		Client: fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objs...).Build(),
+	}
+	return &K8sClient{client: ec, clientset: kubefake.NewSimpleClientset(), scheme: testScheme}, ec
+}
@@ -0,0 +1,44 @@
+// Package client contains wrapper for k8s client
+/*
+ * Copyright (c) 2021, NVIDIA CORPORATION. All rights reserved.
+ */
+package client
+
+import (
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Sentinel errors returned (wrapped) by the typed getters, to be checked with `errors.Is`.
+var (
+	ErrAIStoreNotFound     = errors.New("aistore not found")
+	ErrStatefulSetNotFound = errors.New("statefulset not found")
+	ErrServiceNotFound     = errors.New("service not found")
+
+	// ErrNoProxyConsensus is returned (wrapped) by CheckProxyConsensus when the proxies disagree on the primary.
+	ErrNoProxyConsensus = errors.New("proxies disagree on primary")
+	// ErrNotEnoughNodes is returned (wrapped) by CheckSchedulability when there are fewer schedulable nodes than pods.
+	ErrNotEnoughNodes = errors.New("not enough schedulable nodes")
+	// ErrTargetNotEvacuated is returned (wrapped) by EnsureTargetEvacuated and DeleteEvacuatedPVCsIfExist
+	// when the data of a target may still be needed.
+	ErrTargetNotEvacuated = errors.New("target not evacuated")
+)
+
+// notFoundError wraps a K8s `NotFound` API error with a sentinel error.
+// It matches both the sentinel (`errors.Is`) and the original API error (`apierrors.IsNotFound`).
+type notFoundError struct {
+	sentinel error
+	err      error
+}
+
+func (e *notFoundError) Error() string        { return e.err.Error() }
+func (e *notFoundError) Unwrap() error        { return e.err }
+func (e *notFoundError) Is(target error) bool { return target == e.sentinel }
+
+func wrapNotFound(err, sentinel error) error {
+	if err == nil || !apierrors.IsNotFound(err) {
+		return err
+	}
+	return &notFoundError{sentinel: sentinel, err: err}
+}
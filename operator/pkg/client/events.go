@@ -0,0 +1,59 @@
+// Package client contains wrapper for k8s client
+/*
+ * Copyright (c) 2021, NVIDIA CORPORATION. All rights reserved.
+ */
+package client
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	aisv1 "github.com/ais-operator/api/v1beta1"
+)
+
+// Reason's to be used by event recorder
+const (
+	EventReasonResourceCreated = "ResourceCreated"
+	EventReasonResourceDeleted = "ResourceDeleted"
+	EventReasonFailedDelete    = "FailedDelete"
+)
+
+// RecordEvent records an event for the given object, e.g. AIStore CR, making it visible in `kubectl describe`.
+// No-op if the client doesn't have an event recorder.
+func (c *K8sClient) RecordEvent(obj runtime.Object, eventType, reason, message string) {
+	if c.recorder == nil {
+		return
+	}
+	c.recorder.Event(obj, eventType, reason, message)
+}
+
+// recordOwnerEvent records an event for `owner` if set, otherwise for the AIStore CR controlling `obj`, if any.
+func (c *K8sClient) recordOwnerEvent(owner *aisv1.AIStore, obj client.Object, eventType, reason, message string) {
+	if owner != nil {
+		c.RecordEvent(owner, eventType, reason, message)
+		return
+	}
+	ref := metav1.GetControllerOf(obj)
+	if ref == nil || ref.Kind != "AIStore" {
+		return
+	}
+	owner = &aisv1.AIStore{}
+	owner.SetName(ref.Name)
+	owner.SetNamespace(obj.GetNamespace())
+	owner.SetUID(ref.UID)
+	c.RecordEvent(owner, eventType, reason, message)
+}
+
+func (c *K8sClient) describe(obj client.Object) string {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if kind == "" {
+		if gvk, err := apiutil.GVKForObject(obj, c.scheme); err == nil {
+			kind = gvk.Kind
+		}
+	}
+	return fmt.Sprintf("%s %q", kind, obj.GetName())
+}
@@ -0,0 +1,158 @@
+// Package client contains wrapper for k8s client
+/*
+ * Copyright (c) 2021, NVIDIA CORPORATION. All rights reserved.
+ */
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Outcomes of a K8s API call, as reported by the `outcome` metric label.
+const (
+	OutcomeSuccess       = "success"
+	OutcomeNotFound      = "not_found"
+	OutcomeAlreadyExists = "already_exists"
+	OutcomeConflict      = "conflict"
+	OutcomeError         = "error"
+)
+
+var (
+	clientRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ais_operator_client_requests_total",
+			Help: "Number of K8s API calls made by the AIS operator, by operation, resource kind and outcome.",
+		},
+		[]string{"operation", "kind", "outcome"},
+	)
+	clientRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ais_operator_client_request_duration_seconds",
+			Help:    "Latency of K8s API calls made by the AIS operator, by operation and resource kind.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"operation", "kind"},
+	)
+)
+
+func init() {
+	// Exposed on the manager's metrics endpoint along with the controller-runtime metrics.
+	metrics.Registry.MustRegister(clientRequests, clientRequestDuration)
+}
+
+// instrumentedClient wraps a controller-runtime client, recording the count, outcome and latency
+// of each call in `clientRequests` and `clientRequestDuration`. Successful writes are logged at V(1),
+// failed calls (other than not found) at the default level.
+type instrumentedClient struct {
+	client.Client
+	log logr.Logger
+}
+
+func newInstrumentedClient(c client.Client, log logr.Logger) client.Client {
+	return &instrumentedClient{Client: c, log: log}
+}
+
+func (c *instrumentedClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	return c.observe("get", c.kind(obj), key, func() error { return c.Client.Get(ctx, key, obj) })
+}
+
+func (c *instrumentedClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.observe("list", c.kind(list), client.ObjectKey{}, func() error { return c.Client.List(ctx, list, opts...) })
+}
+
+func (c *instrumentedClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return c.observe("create", c.kind(obj), client.ObjectKeyFromObject(obj),
+		func() error { return c.Client.Create(ctx, obj, opts...) })
+}
+
+func (c *instrumentedClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return c.observe("update", c.kind(obj), client.ObjectKeyFromObject(obj),
+		func() error { return c.Client.Update(ctx, obj, opts...) })
+}
+
+func (c *instrumentedClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return c.observe("patch", c.kind(obj), client.ObjectKeyFromObject(obj),
+		func() error { return c.Client.Patch(ctx, obj, patch, opts...) })
+}
+
+func (c *instrumentedClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	return c.observe("delete", c.kind(obj), client.ObjectKeyFromObject(obj),
+		func() error { return c.Client.Delete(ctx, obj, opts...) })
+}
+
+func (c *instrumentedClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	return c.observe("delete_all_of", c.kind(obj), client.ObjectKey{Namespace: obj.GetNamespace()},
+		func() error { return c.Client.DeleteAllOf(ctx, obj, opts...) })
+}
+
+func (c *instrumentedClient) Status() client.StatusWriter {
+	return &instrumentedStatusWriter{StatusWriter: c.Client.Status(), parent: c}
+}
+
+func (c *instrumentedClient) kind(obj runtime.Object) string {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return "unknown"
+	}
+	return gvk.Kind
+}
+
+func (c *instrumentedClient) observe(operation, kind string, key client.ObjectKey, call func() error) error {
+	start := time.Now()
+	err := call()
+	clientRequestDuration.WithLabelValues(operation, kind).Observe(time.Since(start).Seconds())
+	clientRequests.WithLabelValues(operation, kind, outcome(err)).Inc()
+
+	switch {
+	case err == nil:
+		if operation != "get" && operation != "list" {
+			c.log.V(1).Info("K8s API call", "operation", operation, "kind", kind, "name", key.String())
+		}
+	case apierrors.IsNotFound(err):
+	case apierrors.IsAlreadyExists(err) || apierrors.IsConflict(err):
+		// Expected in the steady state, e.g. from `CreateResourceIfNotExists` or retried on conflict by the callers.
+		c.log.V(1).Info("K8s API call failed", "operation", operation, "kind", kind, "name", key.String(), "error", err.Error())
+	default:
+		c.log.Error(err, "K8s API call failed", "operation", operation, "kind", kind, "name", key.String())
+	}
+	return err
+}
+
+type instrumentedStatusWriter struct {
+	client.StatusWriter
+	parent *instrumentedClient
+}
+
+func (w *instrumentedStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return w.parent.observe("update_status", w.parent.kind(obj), client.ObjectKeyFromObject(obj),
+		func() error { return w.StatusWriter.Update(ctx, obj, opts...) })
+}
+
+func (w *instrumentedStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return w.parent.observe("patch_status", w.parent.kind(obj), client.ObjectKeyFromObject(obj),
+		func() error { return w.StatusWriter.Patch(ctx, obj, patch, opts...) })
+}
+
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case apierrors.IsNotFound(err):
+		return OutcomeNotFound
+	case apierrors.IsAlreadyExists(err):
+		return OutcomeAlreadyExists
+	case apierrors.IsConflict(err):
+		return OutcomeConflict
+	default:
+		return OutcomeError
+	}
+}
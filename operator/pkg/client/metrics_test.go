@@ -0,0 +1,75 @@
+// Package client contains wrapper for k8s client
+/*
+ * Copyright (c) 2021, NVIDIA CORPORATION. All rights reserved.
+ */
+package client
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Client metrics", func() {
+	It("should count calls by operation, kind and outcome", func() {
+		ctx := context.Background()
+		_, ec := newTestClient()
+		c := newInstrumentedClient(ec, logr.Discard())
+		key := client.ObjectKey{Name: "metrics-cm", Namespace: testNamespace}
+
+		count := func(operation, outcome string) float64 {
+			return testutil.ToFloat64(clientRequests.WithLabelValues(operation, "ConfigMap", outcome))
+		}
+		getNotFound, createOK, getOK := count("get", OutcomeNotFound), count("create", OutcomeSuccess), count("get", OutcomeSuccess)
+		createExists, createErr := count("create", OutcomeAlreadyExists), count("create", OutcomeError)
+
+		Expect(c.Get(ctx, key, &corev1.ConfigMap{})).NotTo(Succeed())
+		Expect(c.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}})).To(Succeed())
+		Expect(c.Get(ctx, key, &corev1.ConfigMap{})).To(Succeed())
+		Expect(c.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}})).NotTo(Succeed())
+
+		Expect(count("get", OutcomeNotFound)).To(Equal(getNotFound + 1))
+		Expect(count("create", OutcomeSuccess)).To(Equal(createOK + 1))
+		Expect(count("get", OutcomeSuccess)).To(Equal(getOK + 1))
+		Expect(count("create", OutcomeAlreadyExists)).To(Equal(createExists + 1))
+		Expect(count("create", OutcomeError)).To(Equal(createErr))
+	})
+	It("should log writes at V(1) and failures at the default level", func() {
+		ctx := context.Background()
+		_, ec := newTestClient()
+		ec.updateErr = func(client.Object) error { return apierrors.NewForbidden(schema.GroupResource{}, "", nil) }
+		var lines []string
+		log := funcr.New(func(prefix, args string) { lines = append(lines, args) }, funcr.Options{Verbosity: 1})
+		c := newInstrumentedClient(ec, log)
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "logged-cm", Namespace: testNamespace}}
+
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(cm), &corev1.ConfigMap{})).NotTo(Succeed())
+		Expect(lines).To(BeEmpty())
+		Expect(c.Create(ctx, cm)).To(Succeed())
+		Expect(lines).To(HaveLen(1))
+		Expect(lines[0]).To(ContainSubstring(`"level"=1`))
+		Expect(lines[0]).To(ContainSubstring(`"name"="ais-test/logged-cm"`))
+
+		// Already existing resources are expected, e.g. on requeue, so they aren't logged as errors.
+		duplicate := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: cm.Name, Namespace: cm.Namespace}}
+		Expect(c.Create(ctx, duplicate)).NotTo(Succeed())
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[1]).To(ContainSubstring(`"level"=1`))
+		Expect(lines[1]).To(ContainSubstring(`"operation"="create"`))
+
+		Expect(c.Update(ctx, cm)).NotTo(Succeed())
+		Expect(lines).To(HaveLen(3))
+		Expect(lines[2]).NotTo(ContainSubstring(`"level"=`))
+		Expect(lines[2]).To(ContainSubstring(`"operation"="update"`))
+		Expect(lines[2]).To(ContainSubstring(`"error"=`))
+	})
+})
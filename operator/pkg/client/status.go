@@ -0,0 +1,57 @@
+// Package client contains wrapper for k8s client
+/*
+ * Copyright (c) 2021, NVIDIA CORPORATION. All rights reserved.
+ */
+package client
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aisv1 "github.com/ais-operator/api/v1beta1"
+)
+
+// SetCondition sets the condition in the AIStore CR status and updates the status subresource.
+// The status is written only if the condition actually changed; `LastTransitionTime` is bumped
+// only when the condition's status changes.
+func (c *K8sClient) SetCondition(ctx context.Context, ais *aisv1.AIStore, condition metav1.Condition) (updated bool, err error) {
+	conditions := make([]metav1.Condition, len(ais.Status.Conditions))
+	copy(conditions, ais.Status.Conditions)
+	meta.SetStatusCondition(&conditions, condition)
+	if equality.Semantic.DeepEqual(conditions, ais.Status.Conditions) {
+		return
+	}
+
+	previous := ais.Status.Conditions
+	ais.Status.Conditions = conditions
+	if err = c.client.Status().Update(ctx, ais); err != nil {
+		ais.Status.Conditions = previous
+		return
+	}
+	return true, nil
+}
+
+// PatchAIStoreStatus applies `mutate` to the AIStore status and sends the change as a merge patch
+// to the status subresource. Unlike `Status().Update`, it doesn't conflict with concurrent spec updates.
+func (c *K8sClient) PatchAIStoreStatus(ctx context.Context, ais *aisv1.AIStore, mutate func(*aisv1.AIStoreStatus)) error {
+	patch := client.MergeFrom(ais.DeepCopy())
+	mutate(&ais.Status)
+	return c.client.Status().Patch(ctx, ais, patch)
+}
+
+// SetClusterInfo patches the AIStore status with the cluster map (Smap) version and the number of active targets,
+// as last observed in the AIS cluster (see `GetSmap`). No patch is issued if both are up to date.
+func (c *K8sClient) SetClusterInfo(ctx context.Context, ais *aisv1.AIStore, smapVersion int64, activeTargets int) (updated bool, err error) {
+	if ais.Status.SmapVersion == smapVersion && ais.Status.ActiveTargets == activeTargets {
+		return
+	}
+	err = c.PatchAIStoreStatus(ctx, ais, func(status *aisv1.AIStoreStatus) {
+		status.SmapVersion = smapVersion
+		status.ActiveTargets = activeTargets
+	})
+	return err == nil, err
+}
@@ -0,0 +1,89 @@
+// Package client contains wrapper for k8s client
+/*
+ * Copyright (c) 2021, NVIDIA CORPORATION. All rights reserved.
+ */
+package client
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aisv1 "github.com/ais-operator/api/v1beta1"
+)
+
+var _ = Describe("AIStore status", func() {
+	var (
+		ctx context.Context
+		ais *aisv1.AIStore
+		c   *K8sClient
+		ec  *errClient
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		c, ec = newTestClient(&aisv1.AIStore{ObjectMeta: metav1.ObjectMeta{Name: "ais", Namespace: testNamespace}})
+		ais = &aisv1.AIStore{}
+		Expect(ec.Client.Get(ctx, client.ObjectKey{Name: "ais", Namespace: testNamespace}, ais)).To(Succeed())
+	})
+
+	Describe("SetCondition", func() {
+		It("should only write when the condition changes", func() {
+			condition := metav1.Condition{Type: "Ready", Status: metav1.ConditionFalse, Reason: "Scaling"}
+			updated, err := c.SetCondition(ctx, ais, condition)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+
+			updated, err = c.SetCondition(ctx, ais, condition)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+
+			condition.Status = metav1.ConditionTrue
+			updated, err = c.SetCondition(ctx, ais, condition)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+
+			stored := &aisv1.AIStore{}
+			Expect(ec.Client.Get(ctx, client.ObjectKeyFromObject(ais), stored)).To(Succeed())
+			Expect(stored.Status.Conditions).To(HaveLen(1))
+			Expect(stored.Status.Conditions[0].Status).To(Equal(metav1.ConditionTrue))
+		})
+	})
+
+	Describe("PatchAIStoreStatus", func() {
+		It("should patch the status without conflicting with spec updates", func() {
+			stale := ais.DeepCopy()
+			ais.Spec.Size = 3
+			Expect(ec.Client.Update(ctx, ais)).To(Succeed())
+
+			err := c.PatchAIStoreStatus(ctx, stale, func(status *aisv1.AIStoreStatus) {
+				status.State = aisv1.ConditionReady
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			stored := &aisv1.AIStore{}
+			Expect(ec.Client.Get(ctx, client.ObjectKeyFromObject(ais), stored)).To(Succeed())
+			Expect(stored.Status.State).To(Equal(aisv1.ConditionReady))
+			Expect(stored.Spec.Size).To(BeEquivalentTo(3))
+		})
+	})
+
+	Describe("SetClusterInfo", func() {
+		It("should patch the cluster info only when it changes", func() {
+			updated, err := c.SetClusterInfo(ctx, ais, 5, 3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			updated, err = c.SetClusterInfo(ctx, ais, 5, 3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+
+			stored := &aisv1.AIStore{}
+			Expect(ec.Client.Get(ctx, client.ObjectKeyFromObject(ais), stored)).To(Succeed())
+			Expect(stored.Status.SmapVersion).To(BeEquivalentTo(5))
+			Expect(stored.Status.ActiveTargets).To(Equal(3))
+		})
+	})
+})
@@ -7,17 +7,19 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
+	apiv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -35,8 +37,11 @@ import (
 const (
 	aisFinalizer = "finalize.ais"
 
-	requeueInterval = 10 * time.Second
-	errBackOffTime  = 10 * time.Second
+	requeueInterval    = 10 * time.Second
+	maxRequeueInterval = 20 * time.Second
+	// clusterServingTimeout bounds the request checking if the cluster serves requests (see `isClusterServing`).
+	clusterServingTimeout = 10 * time.Second
+	errBackOffTime        = 10 * time.Second
 )
 
 type (
@@ -54,7 +59,7 @@ type (
 
 func NewAISReconciler(mgr manager.Manager, logger logr.Logger, isExternal bool) *AIStoreReconciler {
 	return &AIStoreReconciler{
-		client:       aisclient.NewClientFromMgr(mgr),
+		client:       aisclient.NewClientFromMgr(mgr, aisclient.ClientOptions{Logger: logger.WithName("client")}),
 		log:          logger,
 		recorder:     mgr.GetEventRecorderFor("ais-controller"),
 		clientParams: make(map[string]*aisapi.BaseParams, 16),
@@ -84,9 +89,7 @@ func (r *AIStoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	}
 
 	if !r.isInitialized(ais) {
-		if !controllerutil.ContainsFinalizer(ais, aisFinalizer) {
-			controllerutil.AddFinalizer(ais, aisFinalizer)
-			err = r.client.Update(ctx, ais)
+		if added, err := r.client.AddFinalizer(ctx, ais, aisFinalizer); added || err != nil {
 			return reconcile.Result{}, err
 		}
 
@@ -106,17 +109,31 @@ func (r *AIStoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			return r.manageError(ctx, ais, aisv1.InstanceDeletionError, err)
 		}
 		if updated {
-			return reconcile.Result{RequeueAfter: requeueInterval}, nil
+			return RequeueWithJitter(requeueInterval, maxRequeueInterval), nil
 		}
-		controllerutil.RemoveFinalizer(ais, aisFinalizer)
-		err = r.client.UpdateIfExists(ctx, ais)
-		if err != nil {
+		_, err = r.client.RemoveFinalizer(ctx, ais, aisFinalizer)
+		if err != nil && !errors.IsNotFound(err) {
 			r.recordError(ais, err, "Failed to update instance")
 			return r.manageError(ctx, ais, aisv1.ResourceUpdateError, err)
 		}
 		return reconcile.Result{}, nil
 	}
 
+	if aisclient.IsReconcilePaused(ais) {
+		r.log.Info("Reconciliation paused, skipping", "annotation", aisclient.AnnotationPaused)
+		return reconcile.Result{}, nil
+	}
+
+	// NOTE: Checked before bootstrapping, so that a cluster created hibernated isn't deployed until woken.
+	if ais.Spec.Hibernate {
+		return r.handleHibernate(ctx, ais)
+	}
+	if ais.Status.State == aisv1.ConditionHibernated {
+		if retry, err := r.WakeCluster(ctx, ais); retry || err != nil {
+			return reconcile.Result{Requeue: retry}, err
+		}
+	}
+
 	if isNewCR(ais) {
 		return r.bootstrapNew(ctx, ais)
 	}
@@ -128,25 +145,14 @@ func (r *AIStoreReconciler) cleanup(ctx context.Context, ais *aisv1.AIStore) (an
 	return cmn.AnyFunc(
 		func() (bool, error) { return r.cleanupTarget(ctx, ais) },
 		func() (bool, error) { return r.cleanupProxy(ctx, ais) },
-		func() (bool, error) { return r.client.DeleteConfigMapIfExists(ctx, statsd.ConfigMapNSName(ais)) },
+		func() (bool, error) { return r.client.DeleteConfigMapIfExists(ctx, ais, statsd.ConfigMapNSName(ais)) },
 		func() (bool, error) { return r.cleanupRBAC(ctx, ais) },
 		func() (bool, error) { return r.cleanupVolumes(ctx, ais) },
 	)
 }
 
 func (r *AIStoreReconciler) attemptGracefulShutdown(ctx context.Context, ais *aisv1.AIStore) {
-	var (
-		params *aisapi.BaseParams
-		err    error
-	)
-	// NOTE: If the AIS operator is deployed on the same K8s cluster as AIStore, we always
-	// attempt to shutdown the cluster through primary proxy. This is done to avoid
-	// unexpected HTTP or K8s service errors.
-	if r.isExternal {
-		params, err = r.getAPIParams(ctx, ais)
-	} else {
-		params, err = r.primaryBaseParams(ctx, ais)
-	}
+	params, err := r.shutdownParams(ctx, ais)
 	if err != nil {
 		r.log.Error(err, "failed to create BaseAPIParams")
 		return
@@ -167,34 +173,95 @@ func (r *AIStoreReconciler) attemptGracefulShutdown(ctx context.Context, ais *ai
 	}
 }
 
+// shutdownParams returns the API params to shut down or decommission the cluster through.
+func (r *AIStoreReconciler) shutdownParams(ctx context.Context, ais *aisv1.AIStore) (*aisapi.BaseParams, error) {
+	// NOTE: If the AIS operator is deployed on the same K8s cluster as AIStore, we always
+	// attempt to shutdown the cluster through primary proxy. This is done to avoid
+	// unexpected HTTP or K8s service errors.
+	if r.isExternal {
+		return r.getAPIParams(ctx, ais)
+	}
+	return r.primaryBaseParams(ctx, ais)
+}
+
+func (r *AIStoreReconciler) handleHibernate(ctx context.Context, ais *aisv1.AIStore) (ctrl.Result, error) {
+	if ais.Status.State == aisv1.ConditionHibernated {
+		return ctrl.Result{}, nil
+	}
+	if err := r.HibernateCluster(ctx, ais); err != nil {
+		r.recordError(ais, err, "Failed to hibernate cluster")
+		return r.manageError(ctx, ais, aisv1.ResourceUpdateError, err)
+	}
+	ais.UnsetConditionReady(aisv1.ConditionHibernated.Str(), "Cluster hibernated")
+	retry, err := r.setStatus(ctx, ais, aisv1.AIStoreStatus{State: aisv1.ConditionHibernated})
+	return ctrl.Result{Requeue: retry}, err
+}
+
+// HibernateCluster gracefully shuts the cluster down and scales both statefulsets to zero.
+// Unlike decommission, the shutdown persists the (meta)data, and the PVCs are retained on scale-down,
+// so the cluster comes back with its data intact (see WakeCluster). The statefulsets not deployed yet are skipped.
+func (r *AIStoreReconciler) HibernateCluster(ctx context.Context, ais *aisv1.AIStore) error {
+	targetSS, err := r.client.GetStatefulSet(ctx, target.StatefulSetNSName(ais))
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && (targetSS.Spec.Replicas == nil || *targetSS.Spec.Replicas > 0) {
+		params, err := r.shutdownParams(ctx, ais)
+		if err != nil {
+			return err
+		}
+		// NOTE: The shutdown request always fails, as the daemon handling it exits before responding back.
+		if err := aisapi.ShutdownCluster(*params); err != nil {
+			r.log.Info("shutdown cluster before hibernating, err: " + err.Error())
+		}
+	}
+	for _, name := range []types.NamespacedName{target.StatefulSetNSName(ais), proxy.StatefulSetNSName(ais)} {
+		if _, err := r.client.UpdateStatefulSetReplicas(ctx, name, 0); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// WakeCluster takes a hibernated cluster out of hibernation. It doesn't scale anything itself: the regular
+// reconcile brings the proxies and then the targets back to the spec size (see `handleTargetScaleUp`), recreating
+// the per-target external LB services, or deploys the cluster if it was created hibernated.
+func (r *AIStoreReconciler) WakeCluster(ctx context.Context, ais *aisv1.AIStore) (retry bool, err error) {
+	state := aisv1.ConditionUpgrading
+	if isNewCR(ais) {
+		state = aisv1.ConditionInitialized
+	}
+	return r.setStatus(ctx, ais, aisv1.AIStoreStatus{State: state})
+}
+
 func (r *AIStoreReconciler) cleanupVolumes(ctx context.Context, ais *aisv1.AIStore) (anyUpdated bool, err error) {
 	if ais.Spec.CleanupData == nil || !*ais.Spec.CleanupData {
 		return
 	}
-	return r.client.DeleteAllPVCsIfExist(ctx, ais.Namespace, target.PodLabels(ais))
+	return r.client.DeleteAllPVCsIfExist(ctx, ais, ais.Namespace, target.PodLabels(ais))
 }
 
 func (r *AIStoreReconciler) cleanupRBAC(ctx context.Context, ais *aisv1.AIStore) (anyUpdated bool, err error) {
 	return cmn.AnyFunc(
 		func() (bool, error) {
 			crb := cmn.NewAISRBACClusterRoleBinding(ais)
-			return r.client.DeleteResourceIfExists(ctx, crb)
+			return r.client.DeleteResourceIfExists(ctx, ais, crb)
 		},
 		func() (bool, error) {
 			cluRole := cmn.NewAISRBACClusterRole(ais)
-			return r.client.DeleteResourceIfExists(ctx, cluRole)
+			return r.client.DeleteResourceIfExists(ctx, ais, cluRole)
 		},
 		func() (bool, error) {
 			rb := cmn.NewAISRBACRoleBinding(ais)
-			return r.client.DeleteResourceIfExists(ctx, rb)
+			return r.client.DeleteResourceIfExists(ctx, ais, rb)
 		},
 		func() (bool, error) {
 			role := cmn.NewAISRBACRole(ais)
-			return r.client.DeleteResourceIfExists(ctx, role)
+			return r.client.DeleteResourceIfExists(ctx, ais, role)
 		},
 		func() (bool, error) {
 			sa := cmn.NewAISServiceAccount(ais)
-			return r.client.DeleteResourceIfExists(ctx, sa)
+			return r.client.DeleteResourceIfExists(ctx, ais, sa)
 		},
 	)
 }
@@ -220,6 +287,12 @@ func (r *AIStoreReconciler) bootstrapNew(ctx context.Context, ais *aisv1.AIStore
 		}
 	}
 
+	// Fail fast if a StorageClass referenced by target mounts is missing, otherwise PVCs get stuck `Pending`.
+	if err = r.checkStorageClasses(ctx, ais); err != nil {
+		r.recordError(ais, err, "Invalid target mounts")
+		return r.manageError(ctx, ais, aisv1.StorageClassError, err)
+	}
+
 	// 1. Create rbac resources
 	err = r.createRBACResources(ctx, ais)
 	if err != nil {
@@ -256,7 +329,7 @@ func (r *AIStoreReconciler) bootstrapNew(ctx context.Context, ais *aisv1.AIStore
 					r.recorder.Event(ais, corev1.EventTypeNormal, EventReasonWaiting, str)
 				}
 			}
-			result.RequeueAfter = requeueInterval
+			result = RequeueWithJitter(requeueInterval, maxRequeueInterval)
 			return
 		}
 	}
@@ -284,7 +357,7 @@ func (r *AIStoreReconciler) bootstrapNew(ctx context.Context, ais *aisv1.AIStore
 		r.recordError(ais, err, "Failed to create Proxy resources")
 		return r.manageError(ctx, ais, aisv1.ProxyCreationError, err)
 	} else if changed {
-		result.RequeueAfter = requeueInterval
+		result = RequeueWithJitter(requeueInterval, maxRequeueInterval)
 		return
 	}
 
@@ -293,7 +366,7 @@ func (r *AIStoreReconciler) bootstrapNew(ctx context.Context, ais *aisv1.AIStore
 		r.recordError(ais, err, "Failed to create Target resources")
 		return r.manageError(ctx, ais, aisv1.TargetCreationError, err)
 	} else if changed {
-		result.RequeueAfter = requeueInterval
+		result = RequeueWithJitter(requeueInterval, maxRequeueInterval)
 		return
 	}
 
@@ -322,6 +395,10 @@ func (r *AIStoreReconciler) handleCREvents(ctx context.Context, ais *aisv1.AISto
 		return r.manageError(ctx, ais, aisv1.RBACManagementError, err)
 	}
 
+	if err = r.syncCommonMetadata(ctx, ais); err != nil {
+		return r.manageError(ctx, ais, aisv1.ResourceUpdateError, err)
+	}
+
 	var proxyReady, targetReady bool
 	if proxyReady, err = r.handleProxyState(ctx, ais); err != nil {
 		return
@@ -335,6 +412,9 @@ func (r *AIStoreReconciler) handleCREvents(ctx context.Context, ais *aisv1.AISto
 	}
 
 	if targetReady && proxyReady {
+		if !r.isClusterServing(ctx, ais) {
+			goto requeue
+		}
 		return r.manageSuccess(ctx, ais)
 	}
 
@@ -345,10 +425,54 @@ requeue:
 		ais.UnsetConditionReady(aisv1.ConditionUpgrading.Str(), "Waiting for cluster to upgrade")
 		_, err = r.setStatus(ctx, ais, aisv1.AIStoreStatus{State: aisv1.ConditionUpgrading})
 	}
-	result.RequeueAfter = 5 * time.Second
+	result = RequeueWithJitter(5*time.Second, requeueInterval)
 	return
 }
 
+// syncCommonMetadata propagates the common labels and annotations of the CR onto the existing statefulsets, services
+// and target PVCs, e.g. after they were edited (see cmn.SyncCommonMetadata). The resources which don't exist yet
+// are skipped, they get the common metadata on creation.
+func (r *AIStoreReconciler) syncCommonMetadata(ctx context.Context, ais *aisv1.AIStore) error {
+	if len(ais.Spec.CommonLabels) == 0 && len(ais.Spec.CommonAnnotations) == 0 {
+		return nil
+	}
+	newObj := func(obj client.Object, name types.NamespacedName) client.Object {
+		obj.SetName(name.Name)
+		obj.SetNamespace(name.Namespace)
+		return obj
+	}
+	objs := []client.Object{
+		newObj(&apiv1.StatefulSet{}, proxy.StatefulSetNSName(ais)),
+		newObj(&apiv1.StatefulSet{}, target.StatefulSetNSName(ais)),
+		newObj(&corev1.Service{}, proxy.HeadlessSVCNSName(ais)),
+		newObj(&corev1.Service{}, proxy.LoadBalancerSVCNSName(ais)),
+		newObj(&corev1.Service{}, target.HeadlessSVCNSName(ais)),
+	}
+	if ais.Spec.EnableExternalLB {
+		for idx := int32(0); idx < ais.Spec.Size; idx++ {
+			objs = append(objs, newObj(&corev1.Service{}, target.LoadBalancerSVCNSName(ais, idx)))
+		}
+	}
+	// The statefulset labels the PVCs created from its claim templates with the pod selector.
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	err := r.client.List(ctx, pvcs, client.InNamespace(ais.Namespace), client.MatchingLabels(target.PodLabels(ais)))
+	if err != nil {
+		return err
+	}
+	for i := range pvcs.Items {
+		objs = append(objs, &pvcs.Items[i])
+	}
+
+	for _, obj := range objs {
+		obj := obj
+		_, err := r.client.UpdateResourceWithRetry(ctx, obj, func() bool { return cmn.SyncCommonMetadata(obj, ais) })
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *AIStoreReconciler) patchRole(ctx context.Context, ais *aisv1.AIStore, role *rbacv1.Role) error {
 	sliceContains := func(keys []string, e string) bool {
 		for _, v := range keys {
@@ -378,7 +502,7 @@ func (r *AIStoreReconciler) patchRole(ctx context.Context, ais *aisv1.AIStore, r
 func (r *AIStoreReconciler) createRBACResources(ctx context.Context, ais *aisv1.AIStore) (err error) {
 	// 1. Create service account if not exists
 	sa := cmn.NewAISServiceAccount(ais)
-	if _, err = r.client.CreateResourceIfNotExists(ctx, nil, sa); err != nil {
+	if _, err = r.client.CreateServiceAccountIfNotExists(ctx, ais, sa); err != nil {
 		r.recordError(ais, err, "Failed to create ServiceAccount")
 		return
 	}
@@ -389,7 +513,7 @@ func (r *AIStoreReconciler) createRBACResources(ctx context.Context, ais *aisv1.
 		exists bool
 	)
 
-	if exists, err = r.client.CreateResourceIfNotExists(ctx, nil, role); err != nil {
+	if exists, err = r.client.CreateRoleIfNotExists(ctx, ais, role); err != nil {
 		r.recordError(ais, err, "Failed to create Role")
 		return
 	}
@@ -404,7 +528,7 @@ func (r *AIStoreReconciler) createRBACResources(ctx context.Context, ais *aisv1.
 
 	// 3. Create binding for the Role
 	rb := cmn.NewAISRBACRoleBinding(ais)
-	if _, err = r.client.CreateResourceIfNotExists(ctx, nil, rb); err != nil {
+	if _, err = r.client.CreateRoleBindingIfNotExists(ctx, ais, rb); err != nil {
 		r.recordError(ais, err, "Failed to create RoleBinding")
 		return
 	}
@@ -478,6 +602,22 @@ func (r *AIStoreReconciler) getAPIParams(ctx context.Context,
 	return
 }
 
+// isClusterServing checks that the cluster serves control-plane requests by listing its buckets,
+// which is a stronger readiness signal than the health of the individual pods.
+func (r *AIStoreReconciler) isClusterServing(ctx context.Context, ais *aisv1.AIStore) bool {
+	params, err := r.getAPIParams(ctx, ais)
+	if err == nil {
+		ctxBack, cancel := context.WithTimeout(ctx, clusterServingTimeout)
+		defer cancel()
+		_, err = aisclient.ListBuckets(ctxBack, params.URL)
+	}
+	if err != nil {
+		r.log.Info("waiting for cluster to serve requests, err: " + err.Error())
+		return false
+	}
+	return true
+}
+
 // misc helpers
 func (r *AIStoreReconciler) manageError(ctx context.Context,
 	ais *aisv1.AIStore, reason aisv1.ErrorReason, err error) (ctrl.Result, error) {
@@ -584,3 +724,12 @@ func _baseParams(_ *aisv1.AIStore, url string) *aisapi.BaseParams {
 		URL:    url,
 	}
 }
+
+// RequeueWithJitter returns a result requeueing the request after a random duration in [base, max),
+// so that many AIStore CRs waiting on the same condition (e.g. pods becoming ready) don't all sync at once.
+func RequeueWithJitter(base, max time.Duration) ctrl.Result {
+	if max <= base {
+		return ctrl.Result{RequeueAfter: base}
+	}
+	return ctrl.Result{RequeueAfter: base + time.Duration(rand.Int63n(int64(max-base)))}
+}
@@ -0,0 +1,148 @@
+// Package controllers contains k8s controller logic for AIS cluster
+/*
+ * Copyright (c) 2021, NVIDIA CORPORATION. All rights reserved.
+ */
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apiv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aisapi "github.com/NVIDIA/aistore/api"
+	aisv1 "github.com/ais-operator/api/v1beta1"
+	"github.com/ais-operator/pkg/resources/proxy"
+	"github.com/ais-operator/pkg/resources/target"
+)
+
+var _ = Describe("Hibernation", func() {
+	const (
+		testNamespace = "ais-test"
+		testImage     = "aistore/aisnode:test"
+		testSize      = int32(2)
+	)
+
+	var (
+		ctx    = context.Background()
+		server *httptest.Server
+		req    ctrl.Request
+	)
+
+	newAIS := func(hibernate bool) *aisv1.AIStore {
+		ais := &aisv1.AIStore{
+			ObjectMeta: metav1.ObjectMeta{Name: "ais", Namespace: testNamespace, Finalizers: []string{aisFinalizer}},
+			Spec: aisv1.AIStoreSpec{
+				Size:             testSize,
+				NodeImage:        testImage,
+				EnableExternalLB: true,
+				Hibernate:        hibernate,
+			},
+		}
+		ais.SetConditionInitialized()
+		ais.SetState(aisv1.ConditionInitialized)
+		return ais
+	}
+
+	// newReadySS returns the statefulset as deployed by the operator, with all the pods ready.
+	newReadySS := func(ss *apiv1.StatefulSet) *apiv1.StatefulSet {
+		ss.Spec.Template.Spec.Containers[0].Image = testImage
+		ss.Status.ReadyReplicas = testSize
+		return ss
+	}
+
+	getAIS := func(c client.Client, ais *aisv1.AIStore) *aisv1.AIStore {
+		fetched := &aisv1.AIStore{}
+		Expect(c.Get(ctx, ais.NamespacedName(), fetched)).To(Succeed())
+		return fetched
+	}
+
+	replicas := func(c client.Client, name types.NamespacedName) int32 {
+		ss := &apiv1.StatefulSet{}
+		Expect(c.Get(ctx, name, ss)).To(Succeed())
+		return *ss.Spec.Replicas
+	}
+
+	setHibernate := func(c client.Client, ais *aisv1.AIStore, hibernate bool) {
+		fetched := getAIS(c, ais)
+		fetched.Spec.Hibernate = hibernate
+		Expect(c.Update(ctx, fetched)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		req = ctrl.Request{NamespacedName: types.NamespacedName{Name: "ais", Namespace: testNamespace}}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should not deploy a new cluster created hibernated", func() {
+		ais := newAIS(true)
+		r, c := newTestReconciler(ais)
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getAIS(c, ais).Status.State).To(Equal(aisv1.ConditionHibernated))
+		err = c.Get(ctx, proxy.StatefulSetNSName(ais), &apiv1.StatefulSet{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		// Once woken, the cluster gets bootstrapped.
+		setHibernate(c, ais, false)
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getAIS(c, ais).Status.State).NotTo(Equal(aisv1.ConditionHibernated))
+	})
+
+	It("should hibernate a running cluster and wake it through the regular scale-up", func() {
+		ais := newAIS(true)
+		ais.SetConditionCreated()
+		ais.SetConditionReady()
+		ais.SetState(aisv1.ConditionReady)
+		r, c := newTestReconciler(ais,
+			newReadySS(proxy.NewProxyStatefulSet(ais, testSize)), newReadySS(target.NewTargetSS(ais)))
+		r.clientParams[ais.NamespacedName().String()] = &aisapi.BaseParams{Client: server.Client(), URL: server.URL}
+
+		_, err := r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getAIS(c, ais).Status.State).To(Equal(aisv1.ConditionHibernated))
+		Expect(replicas(c, proxy.StatefulSetNSName(ais))).To(BeZero())
+		Expect(replicas(c, target.StatefulSetNSName(ais))).To(BeZero())
+
+		setHibernate(c, ais, false)
+
+		// The proxies are scaled up first.
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getAIS(c, ais).Status.State).To(Equal(aisv1.ConditionUpgrading))
+		Expect(replicas(c, proxy.StatefulSetNSName(ais))).To(Equal(testSize))
+		Expect(replicas(c, target.StatefulSetNSName(ais))).To(BeZero())
+
+		// The targets aren't scaled up before their external LB services get an IP.
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicas(c, target.StatefulSetNSName(ais))).To(BeZero())
+		for idx := int32(0); idx < testSize; idx++ {
+			svc := &corev1.Service{}
+			Expect(c.Get(ctx, target.LoadBalancerSVCNSName(ais, idx), svc)).To(Succeed())
+			svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}}
+			Expect(c.Status().Update(ctx, svc)).To(Succeed())
+		}
+
+		_, err = r.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicas(c, target.StatefulSetNSName(ais))).To(Equal(testSize))
+	})
+})
@@ -0,0 +1,50 @@
+// Package controllers contains k8s controller logic for AIS cluster
+/*
+ * Copyright (c) 2021, NVIDIA CORPORATION. All rights reserved.
+ */
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	aisapi "github.com/NVIDIA/aistore/api"
+	aisv1 "github.com/ais-operator/api/v1beta1"
+	aisclient "github.com/ais-operator/pkg/client"
+)
+
+// These tests use Ginkgo (BDD-style Go testing framework). Refer to
+// http://onsi.github.io/ginkgo/ to learn more about Ginkgo.
+
+var testScheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(testScheme))
+	utilruntime.Must(aisv1.AddToScheme(testScheme))
+}
+
+func TestControllers(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Controllers Suite")
+}
+
+// newTestReconciler creates a reconciler over a fake client, returning the client as well to inspect the resources.
+func newTestReconciler(objs ...client.Object) (*AIStoreReconciler, client.Client) {
+	c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objs...).Build()
+	return &AIStoreReconciler{
+		client:       aisclient.NewClient(c, testScheme, aisclient.ClientOptions{}),
+		log:          logf.Log.WithName("test"),
+		recorder:     record.NewFakeRecorder(100),
+		clientParams: make(map[string]*aisapi.BaseParams, 1),
+		isExternal:   true,
+	}, c
+}
@@ -80,10 +80,14 @@ func (r *AIStoreReconciler) initProxies(ctx context.Context, ais *aisv1.AIStore)
 
 func (r *AIStoreReconciler) cleanupProxy(ctx context.Context, ais *aisv1.AIStore) (anyExisted bool, err error) {
 	return cmn.AnyFunc(
-		func() (bool, error) { return r.client.DeleteStatefulSetIfExists(ctx, proxy.StatefulSetNSName(ais)) },
-		func() (bool, error) { return r.client.DeleteServiceIfExists(ctx, proxy.HeadlessSVCNSName(ais)) },
-		func() (bool, error) { return r.client.DeleteServiceIfExists(ctx, proxy.LoadBalancerSVCNSName(ais)) },
-		func() (bool, error) { return r.client.DeleteConfigMapIfExists(ctx, proxy.ConfigMapNSName(ais)) },
+		func() (bool, error) {
+			return r.client.DeleteStatefulSetIfExists(ctx, ais, proxy.StatefulSetNSName(ais))
+		},
+		func() (bool, error) { return r.client.DeleteServiceIfExists(ctx, ais, proxy.HeadlessSVCNSName(ais)) },
+		func() (bool, error) {
+			return r.client.DeleteServiceIfExists(ctx, ais, proxy.LoadBalancerSVCNSName(ais))
+		},
+		func() (bool, error) { return r.client.DeleteConfigMapIfExists(ctx, ais, proxy.ConfigMapNSName(ais)) },
 	)
 }
 
@@ -91,6 +95,10 @@ func (r *AIStoreReconciler) handleProxyState(ctx context.Context, ais *aisv1.AIS
 	if hasLatest, err := r.handleProxyImage(ctx, ais); !hasLatest || err != nil {
 		return false, err
 	}
+	if updated, err := r.client.UpdateStatefulSetNodeSelector(ctx, proxy.StatefulSetNSName(ais),
+		ais.Spec.ProxySpec.NodeSelector); updated || err != nil {
+		return false, err
+	}
 
 	proxySSName := proxy.StatefulSetNSName(ais)
 	// Fetch the latest statefulset for proxies and check if it's spec (for now just replicas), matches the AIS cluster spec.
@@ -179,7 +187,7 @@ func (r *AIStoreReconciler) handleProxyImage(ctx context.Context, ais *aisv1.AIS
 		}
 
 		// Delete the first pod to update it's docker image.
-		return false, r.client.DeletePodIfExists(ctx, types.NamespacedName{
+		return false, r.client.DeletePodIfExists(ctx, ais, types.NamespacedName{
 			Namespace: ais.Namespace,
 			Name:      firstPodName,
 		})
@@ -6,6 +6,7 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	"github.com/ais-operator/pkg/resources/cmn"
@@ -15,8 +16,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	aisapi "github.com/NVIDIA/aistore/api"
-	aisapc "github.com/NVIDIA/aistore/api/apc"
+	aiscluster "github.com/NVIDIA/aistore/cluster"
 	aisv1 "github.com/ais-operator/api/v1beta1"
+	aisclient "github.com/ais-operator/pkg/client"
 	"github.com/ais-operator/pkg/resources/target"
 )
 
@@ -55,14 +57,31 @@ func (r *AIStoreReconciler) initTargets(ctx context.Context, ais *aisv1.AIStore)
 	return
 }
 
+// checkStorageClasses checks that the StorageClasses referenced by target mounts exist.
+func (r *AIStoreReconciler) checkStorageClasses(ctx context.Context, ais *aisv1.AIStore) error {
+	for _, mount := range ais.Spec.TargetSpec.Mounts {
+		if mount.StorageClass == nil || *mount.StorageClass == "" {
+			continue
+		}
+		exists, _, err := r.client.CheckStorageClassExists(ctx, *mount.StorageClass)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("StorageClass %q of mount %q not found", *mount.StorageClass, mount.Path)
+		}
+	}
+	return nil
+}
+
 func (r *AIStoreReconciler) cleanupTarget(ctx context.Context, ais *aisv1.AIStore) (updated bool, err error) {
 	return cmn.AnyFunc(
 		func() (bool, error) { return r.cleanupTargetSS(ctx, ais) },
-		func() (bool, error) { return r.client.DeleteServiceIfExists(ctx, target.HeadlessSVCNSName(ais)) },
+		func() (bool, error) { return r.client.DeleteServiceIfExists(ctx, ais, target.HeadlessSVCNSName(ais)) },
 		func() (bool, error) {
 			return r.client.DeleteAllServicesIfExist(ctx, ais.Namespace, target.ExternalServiceLabels(ais))
 		},
-		func() (bool, error) { return r.client.DeleteConfigMapIfExists(ctx, target.ConfigMapNSName(ais)) },
+		func() (bool, error) { return r.client.DeleteConfigMapIfExists(ctx, ais, target.ConfigMapNSName(ais)) },
 	)
 }
 
@@ -76,13 +95,20 @@ func (r *AIStoreReconciler) cleanupTargetSS(ctx context.Context, ais *aisv1.AISt
 	// If we reach here implies, we didn't attempt to shutdown the cluster yet.
 	// Attempt graceful cluster shutdown followed by deleting target statefulset.
 	r.attemptGracefulShutdown(ctx, ais)
-	return r.client.DeleteStatefulSetIfExists(ctx, targetSS)
+	return r.client.DeleteStatefulSetIfExists(ctx, ais, targetSS)
 }
 
 func (r *AIStoreReconciler) handleTargetState(ctx context.Context, ais *aisv1.AIStore) (ready bool, err error) {
 	if hasLatest, err := r.handleTargetImage(ctx, ais); !hasLatest || err != nil {
 		return false, err
 	}
+	if updated, err := r.handleTargetInitContainer(ctx, ais); updated || err != nil {
+		return false, err
+	}
+	if updated, err := r.client.UpdateStatefulSetNodeSelector(ctx, target.StatefulSetNSName(ais),
+		ais.Spec.TargetSpec.NodeSelector); updated || err != nil {
+		return false, err
+	}
 
 	targetSSName := target.StatefulSetNSName(ais)
 	// Fetch the latest StatefulSet for targets and check if it's spec (for now just replicas), matches the AIS cluster spec.
@@ -116,7 +142,7 @@ func (r *AIStoreReconciler) handleTargetScaleDown(ctx context.Context, ais *aisv
 		ready = true
 		for idx := *ss.Spec.Replicas; idx > ais.Spec.Size; idx-- {
 			svcName := target.LoadBalancerSVCNSName(ais, idx-1)
-			singleExisted, err := r.client.DeleteServiceIfExists(ctx, svcName)
+			singleExisted, err := r.client.DeleteServiceIfExists(ctx, ais, svcName)
 			if err != nil {
 				return false, err
 			}
@@ -138,6 +164,9 @@ func (r *AIStoreReconciler) handleTargetScaleDown(ctx context.Context, ais *aisv
 	return !updated, err
 }
 
+// decommissionTargets decommissions the targets above the spec size one at a time, highest ordinal first, so that
+// their data is migrated before the statefulset is scaled down. It doesn't wait for the rebalance: it reports
+// decommissioning as long as any of the targets is still in the cluster map, for the reconcile to be requeued.
 func (r *AIStoreReconciler) decommissionTargets(ctx context.Context, ais *aisv1.AIStore, actualSize int32) (decommissioning bool, err error) {
 	params, err := r.getAPIParams(ctx, ais)
 	if err != nil {
@@ -149,25 +178,29 @@ func (r *AIStoreReconciler) decommissionTargets(ctx context.Context, ais *aisv1.
 		return false, err
 	}
 
-	toDecommission := 0
+	var next *aiscluster.Snode
 	for idx := actualSize; idx > ais.Spec.Size; idx-- {
 		podName := target.PodName(ais, idx-1)
 		for _, node := range smap.Tmap {
-			if !strings.HasPrefix(node.IntraControlNet.NodeHostname, podName) {
+			if strings.SplitN(node.IntraControlNet.NodeHostname, ".", 2)[0] != podName {
 				continue
 			}
-			toDecommission++
-			if !smap.PresentInMaint(node) {
-				r.log.Info("decommissioning node - " + node.String())
-				_, err = aisapi.DecommissionNode(*params, &aisapc.ActValRmNode{DaemonID: node.ID(), RmUserData: true})
-				if err != nil {
-					return
-				}
+			if smap.PresentInMaint(node) {
+				// The rebalance migrating the data off the target is still running.
+				r.log.Info("waiting for rebalance after decommissioning node - " + node.String())
+				return true, nil
+			}
+			if next == nil {
+				next = node
 			}
 		}
 	}
-	decommissioning = toDecommission != 0
-	return
+	if next == nil {
+		return false, nil
+	}
+	r.log.Info("decommissioning node - " + next.String())
+	_, err = aisclient.StartDecommissionTarget(ctx, params.URL, next.ID())
+	return true, err
 }
 
 func (r *AIStoreReconciler) handleTargetImage(ctx context.Context, ais *aisv1.AIStore) (ready bool, err error) {
@@ -192,6 +225,21 @@ func (r *AIStoreReconciler) handleTargetImage(ctx context.Context, ais *aisv1.AI
 	return true, nil
 }
 
+// handleTargetInitContainer reconciles the init container preparing the mountpaths with the target spec,
+// which triggers a rolling restart of the targets if anything changed.
+func (r *AIStoreReconciler) handleTargetInitContainer(ctx context.Context, ais *aisv1.AIStore) (updated bool, err error) {
+	targetSS := target.StatefulSetNSName(ais)
+	if initContainer := target.NewDiskInitContainer(ais); initContainer != nil {
+		updated, err = r.client.EnsureStatefulSetInitContainer(ctx, targetSS, *initContainer)
+	} else {
+		updated, err = r.client.RemoveStatefulSetInitContainer(ctx, targetSS, target.DiskInitContainerName)
+	}
+	if updated {
+		r.log.Info("target init container updated")
+	}
+	return
+}
+
 func (r *AIStoreReconciler) handleTargetScaleUp(ctx context.Context, ais *aisv1.AIStore, targetSS types.NamespacedName) (ready bool, err error) {
 	if ais.Spec.EnableExternalLB {
 		ready, err = r.enableTargetExternalService(ctx, ais)
@@ -0,0 +1,20 @@
+// Package cmn provides utilities for common AIS cluster resources
+/*
+ * Copyright (c) 2021, NVIDIA CORPORATION. All rights reserved.
+ */
+package cmn
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// These tests use Ginkgo (BDD-style Go testing framework). Refer to
+// http://onsi.github.io/ginkgo/ to learn more about Ginkgo.
+
+func TestCmn(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Cmn Suite")
+}
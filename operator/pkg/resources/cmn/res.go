@@ -6,6 +6,7 @@ package cmn
 
 import (
 	"path"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -225,16 +226,7 @@ func NewAISPodAffinity(ais *aisv1.AIStore, affinity *corev1.Affinity, podLabels
 	}
 
 	if !antiAffinityDisabled {
-		antiAffinity = &corev1.PodAntiAffinity{
-			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
-				{
-					LabelSelector: &metav1.LabelSelector{
-						MatchLabels: podLabels,
-					},
-					TopologyKey: corev1.LabelHostname,
-				},
-			},
-		}
+		antiAffinity = NewPodAntiAffinity(podLabels)
 	}
 
 	if affinity == nil {
@@ -249,6 +241,96 @@ func NewAISPodAffinity(ais *aisv1.AIStore, affinity *corev1.Affinity, podLabels
 	return affinity
 }
 
+// NewPodAntiAffinity requires the pods matching the labels to be scheduled on different nodes.
+func NewPodAntiAffinity(podLabels map[string]string) *corev1.PodAntiAffinity {
+	return &corev1.PodAntiAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+			{
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: podLabels,
+				},
+				TopologyKey: corev1.LabelHostname,
+			},
+		},
+	}
+}
+
+// managementLabels are the label keys the operator relies on to select its resources (see e.g. `target.PodLabels`).
+var managementLabels = map[string]struct{}{"app": {}, "component": {}, "function": {}}
+
+// MergeCommonMetadata adds the AIStore `CommonLabels` and `CommonAnnotations` to the object metadata.
+// Keys already set on the object, the operator's management labels and keys in the `ais.nvidia.com/`
+// domain are left untouched. The label and annotation maps are copied, as they may be shared with selectors.
+func MergeCommonMetadata(obj metav1.Object, ais *aisv1.AIStore) {
+	if labels := mergeMetadata(obj.GetLabels(), ais.Spec.CommonLabels, true); labels != nil {
+		obj.SetLabels(labels)
+	}
+	if annotations := mergeMetadata(obj.GetAnnotations(), ais.Spec.CommonAnnotations, false); annotations != nil {
+		obj.SetAnnotations(annotations)
+	}
+}
+
+// SyncCommonMetadata updates the object metadata of an existing resource with the current AIStore `CommonLabels`
+// and `CommonAnnotations`, overwriting the values which changed. As in MergeCommonMetadata, the operator's management
+// labels and keys in the `ais.nvidia.com/` domain are never touched. Keys removed from the CR are left in place,
+// as they can't be told apart from the ones set by others. Returns true if the metadata changed.
+func SyncCommonMetadata(obj metav1.Object, ais *aisv1.AIStore) (changed bool) {
+	if labels, ok := syncMetadata(obj.GetLabels(), ais.Spec.CommonLabels, true); ok {
+		obj.SetLabels(labels)
+		changed = true
+	}
+	if annotations, ok := syncMetadata(obj.GetAnnotations(), ais.Spec.CommonAnnotations, false); ok {
+		obj.SetAnnotations(annotations)
+		changed = true
+	}
+	return
+}
+
+func syncMetadata(current, common map[string]string, isLabels bool) (map[string]string, bool) {
+	var synced map[string]string
+	for k, v := range common {
+		if isProtectedKey(k, isLabels) {
+			continue
+		}
+		if cur, exists := current[k]; exists && cur == v {
+			continue
+		}
+		if synced == nil {
+			synced = make(map[string]string, len(current)+len(common))
+			for ck, cv := range current {
+				synced[ck] = cv
+			}
+		}
+		synced[k] = v
+	}
+	return synced, synced != nil
+}
+
+func isProtectedKey(key string, isLabels bool) bool {
+	if strings.HasPrefix(key, "ais.nvidia.com/") {
+		return true
+	}
+	_, managed := managementLabels[key]
+	return managed && isLabels
+}
+
+func mergeMetadata(current, common map[string]string, isLabels bool) map[string]string {
+	if len(common) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(current)+len(common))
+	for k, v := range current {
+		merged[k] = v
+	}
+	for k, v := range common {
+		if _, exists := merged[k]; exists || isProtectedKey(k, isLabels) {
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
 func hostPathTypePtr(v corev1.HostPathType) *corev1.HostPathType {
 	return &v
 }
@@ -0,0 +1,83 @@
+// Package cmn provides utilities for common AIS cluster resources
+/*
+ * Copyright (c) 2021, NVIDIA CORPORATION. All rights reserved.
+ */
+package cmn
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	aisv1 "github.com/ais-operator/api/v1beta1"
+)
+
+var _ = Describe("Common metadata", func() {
+	var ais *aisv1.AIStore
+
+	BeforeEach(func() {
+		ais = &aisv1.AIStore{ObjectMeta: metav1.ObjectMeta{Name: "ais"}}
+		ais.Spec.CommonLabels = map[string]string{
+			"team":                   "storage",
+			"app":                    "other",
+			"function":               "other",
+			"ais.nvidia.com/managed": "other",
+		}
+		ais.Spec.CommonAnnotations = map[string]string{"cost-center": "42", "ais.nvidia.com/paused": "true"}
+	})
+
+	newSvc := func() *corev1.Service {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+			Name:   "svc",
+			Labels: map[string]string{"app": "ais", "component": "proxy"},
+		}}
+	}
+
+	Describe("MergeCommonMetadata", func() {
+		It("should add the common metadata without clobbering the management labels", func() {
+			svc := newSvc()
+			MergeCommonMetadata(svc, ais)
+			Expect(svc.Labels).To(Equal(map[string]string{"app": "ais", "component": "proxy", "team": "storage"}))
+			Expect(svc.Annotations).To(Equal(map[string]string{"cost-center": "42"}))
+		})
+
+		It("should keep the values already set on the object", func() {
+			svc := newSvc()
+			svc.Labels["team"] = "compute"
+			MergeCommonMetadata(svc, ais)
+			Expect(svc.Labels).To(HaveKeyWithValue("team", "compute"))
+		})
+
+		It("should not modify the maps shared with the object", func() {
+			svc := newSvc()
+			labels := svc.Labels
+			MergeCommonMetadata(svc, ais)
+			Expect(labels).NotTo(HaveKey("team"))
+		})
+	})
+
+	Describe("SyncCommonMetadata", func() {
+		It("should update the changed values without clobbering the management labels", func() {
+			svc := newSvc()
+			MergeCommonMetadata(svc, ais)
+			Expect(SyncCommonMetadata(svc, ais)).To(BeFalse())
+
+			ais.Spec.CommonLabels["team"] = "compute"
+			ais.Spec.CommonLabels["component"] = "other"
+			ais.Spec.CommonAnnotations["owner"] = "alice"
+			Expect(SyncCommonMetadata(svc, ais)).To(BeTrue())
+			Expect(svc.Labels).To(Equal(map[string]string{"app": "ais", "component": "proxy", "team": "compute"}))
+			Expect(svc.Annotations).To(Equal(map[string]string{"cost-center": "42", "owner": "alice"}))
+			Expect(SyncCommonMetadata(svc, ais)).To(BeFalse())
+		})
+
+		It("should leave the keys removed from the CR in place", func() {
+			svc := newSvc()
+			MergeCommonMetadata(svc, ais)
+			delete(ais.Spec.CommonLabels, "team")
+			Expect(SyncCommonMetadata(svc, ais)).To(BeFalse())
+			Expect(svc.Labels).To(HaveKeyWithValue("team", "storage"))
+		})
+	})
+})
@@ -7,6 +7,7 @@ package proxy
 import (
 	aisapc "github.com/NVIDIA/aistore/api/apc"
 	aisv1 "github.com/ais-operator/api/v1beta1"
+	"github.com/ais-operator/pkg/resources/cmn"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -40,7 +41,7 @@ func NewProxyHeadlessSvc(ais *aisv1.AIStore) *corev1.Service {
 	controlPort := ais.Spec.ProxySpec.IntraControlPort
 	dataPort := ais.Spec.ProxySpec.IntraDataPort
 
-	return &corev1.Service{
+	svc := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      HeadlessSVCName(ais),
 			Namespace: ais.Namespace,
@@ -76,12 +77,14 @@ func NewProxyHeadlessSvc(ais *aisv1.AIStore) *corev1.Service {
 			Selector: PodLabels(ais),
 		},
 	}
+	cmn.MergeCommonMetadata(svc, ais)
+	return svc
 }
 
 func NewProxyLoadBalancerSVC(ais *aisv1.AIStore) *corev1.Service {
 	servicePort := ais.Spec.ProxySpec.ServicePort
 	publicNetPort := ais.Spec.ProxySpec.PublicPort
-	return &corev1.Service{
+	svc := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      loadBalancerSVCName(ais),
 			Namespace: ais.Namespace,
@@ -105,4 +108,6 @@ func NewProxyLoadBalancerSVC(ais *aisv1.AIStore) *corev1.Service {
 			Selector: PodLabels(ais),
 		},
 	}
+	cmn.MergeCommonMetadata(svc, ais)
+	return svc
 }
@@ -48,7 +48,7 @@ func DefaultPrimaryNSName(ais *aisv1.AIStore) types.NamespacedName {
 func NewProxyStatefulSet(ais *aisv1.AIStore, size int32) *apiv1.StatefulSet {
 	ls := PodLabels(ais)
 	proxySpec := proxyPodSpec(ais)
-	return &apiv1.StatefulSet{
+	ss := &apiv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      statefulSetName(ais),
 			Namespace: ais.Namespace,
@@ -69,6 +69,8 @@ func NewProxyStatefulSet(ais *aisv1.AIStore, size int32) *apiv1.StatefulSet {
 			},
 		},
 	}
+	cmn.MergeCommonMetadata(ss, ais)
+	return ss
 }
 
 /////////////////
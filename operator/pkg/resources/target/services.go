@@ -13,6 +13,7 @@ import (
 
 	aisapc "github.com/NVIDIA/aistore/api/apc"
 	aisv1 "github.com/ais-operator/api/v1beta1"
+	"github.com/ais-operator/pkg/resources/cmn"
 )
 
 func headlessSVCName(ais *aisv1.AIStore) string {
@@ -52,7 +53,7 @@ func NewTargetHeadlessSvc(ais *aisv1.AIStore) *corev1.Service {
 	servicePort := ais.Spec.TargetSpec.ServicePort
 	controlPort := ais.Spec.TargetSpec.IntraControlPort
 	dataPort := ais.Spec.TargetSpec.IntraDataPort
-	return &corev1.Service{
+	svc := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      headlessSVCName(ais),
 			Namespace: ais.Namespace,
@@ -92,6 +93,8 @@ func NewTargetHeadlessSvc(ais *aisv1.AIStore) *corev1.Service {
 			},
 		},
 	}
+	cmn.MergeCommonMetadata(svc, ais)
+	return svc
 }
 
 func NewTargetLoadBalancerSVC(ais *aisv1.AIStore, targetIndex int32) *corev1.Service {
@@ -99,7 +102,7 @@ func NewTargetLoadBalancerSVC(ais *aisv1.AIStore, targetIndex int32) *corev1.Ser
 	publicNetPort := ais.Spec.TargetSpec.PublicPort
 	selectors := PodLabels(ais)
 	selectors["statefulset.kubernetes.io/pod-name"] = fmt.Sprintf("%s-%d", statefulSetName(ais), targetIndex)
-	return &corev1.Service{
+	svc := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      loadBalancerSVCName(ais, targetIndex),
 			Namespace: ais.Namespace,
@@ -121,6 +124,8 @@ func NewTargetLoadBalancerSVC(ais *aisv1.AIStore, targetIndex int32) *corev1.Ser
 			Selector: selectors,
 		},
 	}
+	cmn.MergeCommonMetadata(svc, ais)
+	return svc
 }
 
 func NewLoadBalancerSVCList(ais *aisv1.AIStore) []*corev1.Service {
@@ -20,6 +20,9 @@ import (
 	"github.com/ais-operator/pkg/resources/proxy"
 )
 
+// DiskInitContainerName is the name of the target init container preparing the mountpaths.
+const DiskInitContainerName = "prepare-disks"
+
 func statefulSetName(ais *aisv1.AIStore) string {
 	return ais.Name + "-" + aisapc.Target
 }
@@ -39,6 +42,12 @@ func PodLabels(ais *aisv1.AIStore) map[string]string {
 	}
 }
 
+// BuildTargetAntiAffinity returns the affinity placing at most one target (matching the labels) per node.
+// Use `client.CheckSchedulability` to confirm that there are enough nodes for all the targets.
+func BuildTargetAntiAffinity(labels map[string]string) *corev1.Affinity {
+	return &corev1.Affinity{PodAntiAffinity: cmn.NewPodAntiAffinity(labels)}
+}
+
 func NewTargetSS(ais *aisv1.AIStore) *apiv1.StatefulSet {
 	ls := PodLabels(ais)
 	var optionals []corev1.EnvVar
@@ -53,7 +62,7 @@ func NewTargetSS(ais *aisv1.AIStore) *apiv1.StatefulSet {
 		optionals = append(optionals, cmn.EnvFromValue(cmn.EnvGCPCredsPath, "/var/gcp/gcp.json"))
 	}
 
-	return &apiv1.StatefulSet{
+	ss := &apiv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      statefulSetName(ais),
 			Namespace: ais.Namespace,
@@ -142,10 +151,37 @@ func NewTargetSS(ais *aisv1.AIStore) *apiv1.StatefulSet {
 			},
 		},
 	}
+	if initContainer := NewDiskInitContainer(ais); initContainer != nil {
+		ss.Spec.Template.Spec.InitContainers = append(ss.Spec.Template.Spec.InitContainers, *initContainer)
+	}
+	cmn.MergeCommonMetadata(ss, ais)
+	return ss
+}
+
+// NewDiskInitContainer returns the init container preparing the mountpaths, as defined by `DiskInitContainer`
+// of the target spec, or nil if it isn't defined.
+func NewDiskInitContainer(ais *aisv1.AIStore) *corev1.Container {
+	spec := ais.Spec.TargetSpec.DiskInitContainer
+	if spec == nil {
+		return nil
+	}
+	return &corev1.Container{
+		Name:            DiskInitContainerName,
+		Image:           spec.Image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         spec.Command,
+		Args:            spec.Args,
+		SecurityContext: ais.Spec.TargetSpec.ContainerSecurity,
+		VolumeMounts:    mountpathVolumeMounts(ais),
+	}
 }
 
 func volumeMounts(ais *aisv1.AIStore) []corev1.VolumeMount {
-	vols := cmn.NewAISVolumeMounts(ais)
+	return append(cmn.NewAISVolumeMounts(ais), mountpathVolumeMounts(ais)...)
+}
+
+func mountpathVolumeMounts(ais *aisv1.AIStore) []corev1.VolumeMount {
+	vols := make([]corev1.VolumeMount, 0, len(ais.Spec.TargetSpec.Mounts))
 	for _, res := range ais.Spec.TargetSpec.Mounts {
 		vols = append(vols, corev1.VolumeMount{
 			Name:      ais.Name + strings.ReplaceAll(res.Path, "/", "-"),
@@ -189,6 +225,8 @@ func targetVC(ais *aisv1.AIStore) []corev1.PersistentVolumeClaim {
 				Selector:         res.Selector,
 			},
 		})
+		// Only applies to the PVCs created from now on, the claim templates of an existing statefulset are immutable.
+		cmn.MergeCommonMetadata(&pvcs[len(pvcs)-1], ais)
 	}
 	return pvcs
 }
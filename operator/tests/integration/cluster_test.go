@@ -143,7 +143,7 @@ var _ = Describe("Run Controller", func() {
 			newNS, nsExists := tutils.CreateNSIfNotExists(ctx, k8sClient, testNSAnotherName)
 			if !nsExists {
 				defer func() {
-					_, err := k8sClient.DeleteResourceIfExists(ctx, newNS)
+					_, err := k8sClient.DeleteResourceIfExists(ctx, nil, newNS)
 					Expect(err).To(BeNil())
 				}()
 			}
@@ -120,7 +120,7 @@ var _ = BeforeSuite(func(done Done) {
 		Scheme: scheme.Scheme,
 	})
 
-	k8sClient = aisclient.NewClientFromMgr(mgr)
+	k8sClient = aisclient.NewClientFromMgr(mgr, aisclient.ClientOptions{})
 	Expect(err).NotTo(HaveOccurred())
 	Expect(k8sClient).NotTo(BeNil())
 
@@ -158,7 +158,7 @@ var _ = BeforeSuite(func(done Done) {
 var _ = AfterSuite(func() {
 	By("tearing down the test environment")
 	if !nsExists && testNS != nil {
-		_, err := k8sClient.DeleteResourceIfExists(context.Background(), testNS)
+		_, err := k8sClient.DeleteResourceIfExists(context.Background(), nil, testNS)
 		Expect(err).NotTo(HaveOccurred())
 	}
 	err := testEnv.Stop()
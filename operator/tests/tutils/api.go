@@ -54,7 +54,7 @@ func DestroyCluster(_ context.Context, client *aisclient.K8sClient,
 		intervals = []interface{}{time.Minute, time.Second}
 	}
 
-	_, err := client.DeleteResourceIfExists(context.Background(), cluster)
+	_, err := client.DeleteResourceIfExists(context.Background(), nil, cluster)
 	Expect(err).Should(Succeed())
 	Eventually(func() bool {
 		return checkClusterExists(context.Background(), client, cluster.NamespacedName())